@@ -0,0 +1,49 @@
+package zmq
+
+import (
+	"syscall"
+)
+
+// Error numbers specific to zeromq, with the values used by libzmq
+const hausnumero = 156384712
+
+var (
+	// ErrFSM is returned when a socket can not send or receive in its
+	// current state, like a request socket sending twice in a row
+	ErrFSM = syscall.Errno(hausnumero + 51)
+	// ErrTerminated is returned by the sockets of a terminated or shut
+	// down context
+	ErrTerminated = syscall.Errno(hausnumero + 53)
+)
+
+// Sender sends messages to a socket
+type Sender interface {
+	Send(data []byte, flag SendFlag) error
+	SendMultipart(data [][]byte, flag SendFlag) error
+}
+
+// Receiver receives messages from a socket
+type Receiver interface {
+	Recv(flag SendFlag) (*MessagePart, error)
+	RecvMultipart(flag SendFlag) (*MessageMultipart, error)
+}
+
+// SocketAPI is the interface of sockets used by applications. It is
+// implemented by *Socket and by fake sockets in tests.
+type SocketAPI interface {
+	Sender
+	Receiver
+	Bind(address string) error
+	Unbind(address string) error
+	Connect(address string) error
+	Disconnect(address string) error
+	Close() error
+	Subscribe(prefix []byte) error
+	Unsubscribe(prefix []byte) error
+	GetOptionInt(option SocketOptionInt) (int, error)
+	SetOptionInt(option SocketOptionInt, value int) error
+	GetOptionBytes(option SocketOptionString) ([]byte, error)
+	SetOptionBytes(option SocketOptionString, value []byte) error
+}
+
+var _ SocketAPI = (*Socket)(nil)
@@ -0,0 +1,344 @@
+package zmq
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fatal errors of the binary star state machine
+var (
+	ErrDualActives  = errors.New("zmq: binary star fatal error, dual actives")
+	ErrDualPassives = errors.New("zmq: binary star fatal error, dual passives")
+)
+
+// Client request refused by the state machine, the request is discarded
+var errRequestRejected = errors.New("zmq: binary star rejected client request")
+
+// BinaryStarState identifies the state of a binary star server
+type BinaryStarState int
+
+// Available binary star states
+const (
+	// StatePrimary is the primary server waiting for its peer
+	StatePrimary BinaryStarState = iota + 1
+	// StateBackup is the backup server waiting for its peer
+	StateBackup
+	// StateActive is the server accepting client requests
+	StateActive
+	// StatePassive is the server rejecting client requests
+	StatePassive
+)
+
+func (s BinaryStarState) String() string {
+	switch s {
+	case StatePrimary:
+		return "primary"
+	case StateBackup:
+		return "backup"
+	case StateActive:
+		return "active"
+	case StatePassive:
+		return "passive"
+	}
+	return "unknown"
+}
+
+// Events of the binary star state machine.
+// Peer events are the state published by the peer.
+type binaryStarEvent int
+
+const (
+	peerPrimary   = binaryStarEvent(StatePrimary)
+	peerBackup    = binaryStarEvent(StateBackup)
+	peerActive    = binaryStarEvent(StateActive)
+	peerPassive   = binaryStarEvent(StatePassive)
+	clientRequest = binaryStarEvent(5)
+)
+
+// VoterHandler processes a client request readable on the voter socket
+type VoterHandler func(soc *Socket) error
+
+type binaryStarVoter struct {
+	socket  *Socket
+	handler VoterHandler
+}
+
+// BinaryStar is a server of an active/passive high availability pair.
+// Both servers publish their state to each other. Client requests received
+// on voter sockets are only handled by the active server and trigger the
+// failover when the active peer stopped sending its state.
+type BinaryStar struct {
+	// HeartbeatInterval is the delay between two states sent to the peer.
+	// The peer is considered dead after two missed heartbeats.
+	HeartbeatInterval time.Duration
+
+	ctx        *Context
+	mu         sync.Mutex
+	state      BinaryStarState
+	peerExpiry time.Time
+	statepub   *Socket
+	statesub   *Socket
+	voters     []*binaryStarVoter
+
+	onActive      func()
+	onPassive     func()
+	onStateChange func(from, to BinaryStarState)
+
+	err     error
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewBinaryStar creates a binary star server, primary or backup.
+// The state is published on the local endpoint and the peer state
+// is received from the remote endpoint.
+func NewBinaryStar(ctx *Context, primary bool, local, remote string) (*BinaryStar, error) {
+	b := &BinaryStar{
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		ctx:               ctx,
+		state:             StateBackup,
+	}
+	if primary {
+		b.state = StatePrimary
+	}
+	var err error
+	b.statepub, err = ctx.NewSocket(Pub)
+	if err != nil {
+		return nil, err
+	}
+	b.statesub, err = ctx.NewSocket(Sub)
+	if err != nil {
+		b.closeSockets()
+		return nil, err
+	}
+	all := ""
+	err = b.statesub.SetOptionString(Subscribe, &all)
+	if err == nil {
+		err = b.statepub.Bind(local)
+	}
+	if err == nil {
+		err = b.statesub.Connect(remote)
+	}
+	if err != nil {
+		b.closeSockets()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Voter creates a socket bound to the endpoint whose incoming requests are
+// client requests. The handler is only called when the server is active,
+// otherwise the request is discarded.
+func (b *BinaryStar) Voter(endpoint string, socketType SocketType, handler VoterHandler) (*Socket, error) {
+	soc, err := b.ctx.NewSocket(socketType)
+	if err != nil {
+		return nil, err
+	}
+	err = soc.Bind(endpoint)
+	if err != nil {
+		soc.Close()
+		return nil, err
+	}
+	b.voters = append(b.voters, &binaryStarVoter{socket: soc, handler: handler})
+	return soc, nil
+}
+
+// OnActive registers a function called when the server becomes active
+func (b *BinaryStar) OnActive(fn func()) {
+	b.onActive = fn
+}
+
+// OnPassive registers a function called when the server becomes passive
+func (b *BinaryStar) OnPassive(fn func()) {
+	b.onPassive = fn
+}
+
+// OnStateChange registers a function called on every state transition
+func (b *BinaryStar) OnStateChange(fn func(from, to BinaryStarState)) {
+	b.onStateChange = fn
+}
+
+// State returns the current state of the server
+func (b *BinaryStar) State() BinaryStarState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *BinaryStar) setState(state BinaryStarState) {
+	b.mu.Lock()
+	from := b.state
+	b.state = state
+	b.mu.Unlock()
+	if b.onStateChange != nil {
+		b.onStateChange(from, state)
+	}
+	if state == StateActive && b.onActive != nil {
+		b.onActive()
+	}
+	if state == StatePassive && b.onPassive != nil {
+		b.onPassive()
+	}
+}
+
+// Execute the state machine for the given event.
+// errRequestRejected is returned when a client request must be discarded.
+func (b *BinaryStar) execute(event binaryStarEvent) error {
+	peerDead := !time.Now().Before(b.peerExpiry)
+	switch b.State() {
+	case StatePrimary:
+		switch {
+		case event == peerBackup:
+			b.setState(StateActive)
+		case event == peerActive:
+			b.setState(StatePassive)
+		case event == clientRequest && peerDead:
+			// Backup did not show up, it is not acting as active
+			b.setState(StateActive)
+		case event == clientRequest:
+			// The backup may still be active after a failover
+			return errRequestRejected
+		}
+	case StateBackup:
+		switch event {
+		case peerActive:
+			b.setState(StatePassive)
+		case clientRequest:
+			return errRequestRejected
+		}
+	case StateActive:
+		if event == peerActive {
+			return ErrDualActives
+		}
+	case StatePassive:
+		switch {
+		case event == peerPrimary, event == peerBackup:
+			// Peer is restarting, it will become passive
+			b.setState(StateActive)
+		case event == peerPassive:
+			return ErrDualPassives
+		case event == clientRequest && peerDead:
+			// Failover triggered by the client request
+			b.setState(StateActive)
+		case event == clientRequest:
+			return errRequestRejected
+		}
+	}
+	return nil
+}
+
+// Start the state machine and voters handling in a new goroutine
+func (b *BinaryStar) Start() {
+	b.done = make(chan struct{})
+	b.stopped = make(chan struct{})
+	go b.run()
+}
+
+// Close stops the server and closes its sockets, voter sockets included.
+// The error which interrupted the server, if any, is returned.
+func (b *BinaryStar) Close() error {
+	if b.done != nil {
+		close(b.done)
+		<-b.stopped
+	}
+	err := b.closeSockets()
+	if b.err != nil {
+		return b.err
+	}
+	return err
+}
+
+func (b *BinaryStar) closeSockets() error {
+	sockets := []*Socket{b.statepub, b.statesub}
+	for _, voter := range b.voters {
+		sockets = append(sockets, voter.socket)
+	}
+	var err error
+	for _, soc := range sockets {
+		if soc == nil {
+			continue
+		}
+		soc.SetOptionInt(Linger, 0)
+		cerr := soc.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (b *BinaryStar) run() {
+	defer close(b.stopped)
+	b.peerExpiry = time.Now().Add(2 * b.HeartbeatInterval)
+	sendStateAt := time.Now()
+	items := PollItems{&PollItem{Socket: b.statesub, Events: Pollin}}
+	for _, voter := range b.voters {
+		items = append(items, &PollItem{Socket: voter.socket, Events: Pollin})
+	}
+	for {
+		select {
+		case <-b.done:
+			return
+		default:
+		}
+		timeout := sendStateAt.Sub(time.Now())
+		if timeout < 0 {
+			timeout = 0
+		}
+		_, err := items.Poll(timeout)
+		if err != nil {
+			b.err = err
+			return
+		}
+
+		if items[0].REvents&Pollin != 0 {
+			msg, err := b.statesub.Recv(0)
+			if err != nil {
+				b.err = err
+				return
+			}
+			state, cerr := strconv.Atoi(string(msg.Data))
+			msg.Close()
+			if cerr == nil {
+				b.peerExpiry = time.Now().Add(2 * b.HeartbeatInterval)
+				err = b.execute(binaryStarEvent(state))
+				if err != nil {
+					b.err = err
+					return
+				}
+			}
+		}
+		for i, voter := range b.voters {
+			if items[i+1].REvents&Pollin == 0 {
+				continue
+			}
+			err = b.execute(clientRequest)
+			if err == errRequestRejected {
+				msg, err := voter.socket.RecvMultipart(0)
+				if err != nil {
+					b.err = err
+					return
+				}
+				msg.Close()
+				continue
+			}
+			err = voter.handler(voter.socket)
+			if err != nil {
+				b.err = err
+				return
+			}
+		}
+
+		if !time.Now().Before(sendStateAt) {
+			state := strconv.Itoa(int(b.State()))
+			err = b.statepub.Send([]byte(state), 0)
+			if err != nil {
+				b.err = err
+				return
+			}
+			sendStateAt = time.Now().Add(b.HeartbeatInterval)
+		}
+	}
+}
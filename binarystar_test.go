@@ -0,0 +1,100 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Echo the request received on a router voter
+func echoVoter(soc *Socket) error {
+	msg, err := soc.RecvMultipart(0)
+	if err != nil {
+		return err
+	}
+	defer msg.Close()
+	return soc.SendMultipart(msg.Data, 0)
+}
+
+func newTestBinaryStar(t *testing.T, ctx *Context, primary bool, local, remote, frontend string) *BinaryStar {
+	bstar, err := NewBinaryStar(ctx, primary, local, remote)
+	if err != nil {
+		t.Fatal("Error on binary star creation", err)
+	}
+	bstar.HeartbeatInterval = 50 * time.Millisecond
+	_, err = bstar.Voter(frontend, Router, echoVoter)
+	if err != nil {
+		t.Fatal("Error on binary star voter creation", err)
+	}
+	return bstar
+}
+
+func waitState(t *testing.T, bstar *BinaryStar, expected BinaryStarState) {
+	deadline := time.Now().Add(2 * time.Second)
+	for bstar.State() != expected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if bstar.State() != expected {
+		t.Fatalf("Expected state %s, got %s", expected, bstar.State())
+	}
+}
+
+func TestBinaryStarFailover(t *testing.T) {
+	env := &Env{Tester: t}
+	env.setupEnv()
+	defer env.destroyEnv()
+	primaryState := InprocEndpoint + "_bstar_primary"
+	backupState := InprocEndpoint + "_bstar_backup"
+	primaryFrontend := InprocEndpoint + "_bstar_primary_frontend"
+	backupFrontend := InprocEndpoint + "_bstar_backup_frontend"
+
+	primary := newTestBinaryStar(t, env.Context, true, primaryState, backupState, primaryFrontend)
+	backup := newTestBinaryStar(t, env.Context, false, backupState, primaryState, backupFrontend)
+	var transitions []BinaryStarState
+	backup.OnStateChange(func(from, to BinaryStarState) {
+		transitions = append(transitions, to)
+	})
+	primary.Start()
+	backup.Start()
+
+	waitState(t, primary, StateActive)
+	waitState(t, backup, StatePassive)
+
+	data := [][]byte{[]byte("request")}
+	client, err := NewReliableReq(env.Context, primaryFrontend, time.Second, 1)
+	if err != nil {
+		t.Fatal("Error on client creation", err)
+	}
+	defer client.Close()
+	reply, err := client.Request(data)
+	if err != nil {
+		t.Fatal("Error on request to primary", err)
+	}
+	if !reflect.DeepEqual(reply, data) {
+		t.Fatalf("Expected reply %q, got %q", data, reply)
+	}
+
+	// Kill the primary, the next client request fails over to the backup
+	primary.Close()
+	time.Sleep(3 * primary.HeartbeatInterval)
+	failover, err := NewReliableReq(env.Context, backupFrontend, time.Second, 1)
+	if err != nil {
+		t.Fatal("Error on client creation", err)
+	}
+	defer failover.Close()
+	reply, err = failover.Request(data)
+	if err != nil {
+		t.Fatal("Error on request to backup", err)
+	}
+	if !reflect.DeepEqual(reply, data) {
+		t.Fatalf("Expected reply %q, got %q", data, reply)
+	}
+	if backup.State() != StateActive {
+		t.Fatalf("Expected backup state to be active, got %s", backup.State())
+	}
+	backup.Close()
+	expected := []BinaryStarState{StatePassive, StateActive}
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+	}
+}
@@ -0,0 +1,71 @@
+package zmq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Capability identifies an optional feature of the zmq library
+type Capability string
+
+// Capabilities reported by Has. Transports return ErrNotSupported when
+// their capability is missing. CapCurve and CapGssapi are only reported:
+// the sockets have no options for these mechanisms.
+const (
+	CapCurve  = Capability("curve")
+	CapIpc    = Capability("ipc")
+	CapPgm    = Capability("pgm")
+	CapNorm   = Capability("norm")
+	CapTipc   = Capability("tipc")
+	CapGssapi = Capability("gssapi")
+	CapDraft  = Capability("draft")
+)
+
+// ErrNotSupported is returned when a feature is not available in the zmq
+// library or was excluded by build tags
+var ErrNotSupported = errors.New("zmq: not supported")
+
+// Capabilities needed by the transports
+var transportCapabilities = map[string]Capability{
+	"ipc":  CapIpc,
+	"pgm":  CapPgm,
+	"epgm": CapPgm,
+	"norm": CapNorm,
+	"tipc": CapTipc,
+}
+
+// Capabilities already looked up, they do not change during the process
+var capabilities sync.Map
+
+// Has reports whether the capability is available. The draft API is
+// only available when built with the zmq_draft tag.
+func Has(capability Capability) bool {
+	if capability == CapDraft && !draftAPI {
+		return false
+	}
+	if available, ok := capabilities.Load(capability); ok {
+		return available.(bool)
+	}
+	available := has(capability)
+	capabilities.Store(capability, available)
+	return available
+}
+
+func notSupported(capability Capability) error {
+	return fmt.Errorf("%w: %s", ErrNotSupported, capability)
+}
+
+// checkTransport returns ErrNotSupported when the transport of the
+// address is not available
+func checkTransport(address string) error {
+	transport, _, ok := strings.Cut(address, "://")
+	if !ok {
+		return nil
+	}
+	if capability, ok := transportCapabilities[transport]; ok && !Has(capability) {
+		return notSupported(capability)
+	}
+	return nil
+}
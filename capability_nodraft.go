@@ -0,0 +1,7 @@
+//go:build !zmq_draft
+
+package zmq
+
+// draftAPI is set by the zmq_draft tag, when the zmq library is built
+// with the draft API
+const draftAPI = false
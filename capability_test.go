@@ -0,0 +1,59 @@
+package zmq
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	major, minor, patch := Version()
+	hmajor, hminor, hpatch := headerVersion()
+	if major != hmajor || minor != hminor || patch != hpatch {
+		t.Fatalf("Expected version %d.%d.%d, got %d.%d.%d", hmajor, hminor, hpatch, major, minor, patch)
+	}
+}
+
+func TestHas(t *testing.T) {
+	for _, capability := range []Capability{CapCurve, CapIpc, CapPgm, CapNorm, CapTipc, CapGssapi} {
+		// The second call is answered by the cache
+		if Has(capability) != has(capability) || Has(capability) != has(capability) {
+			t.Fatalf("Expected %s to be reported as by the library", capability)
+		}
+	}
+	if Has(Capability("unknown")) {
+		t.Fatal("Expected unknown capability to be unavailable")
+	}
+}
+
+func TestCheckTransport(t *testing.T) {
+	for transport, capability := range transportCapabilities {
+		err := checkTransport(transport + "://endpoint")
+		if Has(capability) && err != nil {
+			t.Fatalf("Expected %s transport to be available, got %v", transport, err)
+		}
+		if !Has(capability) && !errors.Is(err, ErrNotSupported) {
+			t.Fatalf("Expected ErrNotSupported on %s transport, got %v", transport, err)
+		}
+	}
+	for _, address := range []string{"tcp://127.0.0.1:5555", "inproc://name", "invalid"} {
+		if err := checkTransport(address); err != nil {
+			t.Fatalf("Expected %s to be left to the library, got %v", address, err)
+		}
+	}
+}
+
+func TestTransportNotSupported(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pub}
+	env.setupEnv()
+	defer env.destroyEnv()
+	err := env.server.Bind("pgm://127.0.0.1;239.192.1.1:5555")
+	if Has(CapPgm) {
+		return
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Expected ErrNotSupported on pgm bind, got %v", err)
+	}
+	if err.Error() != "zmq: not supported: pgm" {
+		t.Fatalf("Unexpected error message %q", err)
+	}
+}
@@ -0,0 +1,91 @@
+package clone
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+const (
+	snapshotEndpoint  = "inproc://clone_snapshot"
+	publisherEndpoint = "inproc://clone_publisher"
+	collectorEndpoint = "inproc://clone_collector"
+)
+
+func TestKVMsgFrames(t *testing.T) {
+	msg := NewKVMsg("/config/key", []byte("value"))
+	msg.Sequence = 42
+	msg.Props["ttl"] = "1.5"
+	msg.Props["origin"] = "test"
+	decoded, err := DecodeKVMsg(msg.Frames())
+	if err != nil {
+		t.Fatal("Error on key-value message decoding", err)
+	}
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Fatalf("Expected decoded message %+v, got %+v", msg, decoded)
+	}
+	_, err = DecodeKVMsg([][]byte{[]byte("key")})
+	if err != ErrMalformedMessage {
+		t.Fatalf("Expected error %q, got %q", ErrMalformedMessage, err)
+	}
+}
+
+// Wait until the key has the expected value in the map
+func waitValue(t *testing.T, m *Map, key string, expected []byte) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		value, _ := m.Get(key)
+		if reflect.DeepEqual(value, expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	value, _ := m.Get(key)
+	t.Fatalf("Expected key %q to be %q, got %q", key, expected, value)
+}
+
+func TestMapSync(t *testing.T) {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	defer ctx.Destroy()
+
+	server, err := NewServer(ctx, snapshotEndpoint, publisherEndpoint, collectorEndpoint)
+	if err != nil {
+		t.Fatal("Error on server creation", err)
+	}
+	server.HeartbeatInterval = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	first, err := NewMap(ctx, snapshotEndpoint, publisherEndpoint, collectorEndpoint, "/config/")
+	if err != nil {
+		t.Fatal("Error on map creation", err)
+	}
+	defer first.Close()
+	first.Set("/config/a", []byte("1"), 0)
+	first.Set("/config/ephemeral", []byte("2"), 200*time.Millisecond)
+	first.Set("/other/b", []byte("3"), 0)
+	waitValue(t, first, "/config/a", []byte("1"))
+
+	// A late joiner gets the current state from the snapshot
+	late, err := NewMap(ctx, snapshotEndpoint, publisherEndpoint, collectorEndpoint, "/config/")
+	if err != nil {
+		t.Fatal("Error on map creation", err)
+	}
+	defer late.Close()
+	waitValue(t, late, "/config/a", []byte("1"))
+	if _, ok := late.Get("/other/b"); ok {
+		t.Fatal("Expected key outside of the subtree to be filtered")
+	}
+
+	// Keys with a ttl expire on all clients
+	waitValue(t, first, "/config/ephemeral", nil)
+	waitValue(t, late, "/config/ephemeral", nil)
+
+	first.Delete("/config/a")
+	waitValue(t, late, "/config/a", nil)
+}
@@ -0,0 +1,98 @@
+package clone
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// Number of frames of a key-value message
+const kvFrames = 5
+
+// ErrMalformedMessage is returned when a received message
+// is not a valid key-value message
+var ErrMalformedMessage = errors.New("clone: malformed key-value message")
+
+// KVMsg is a key-value update as transmitted by the Clone protocol.
+// On the wire, it is a multipart message of five frames:
+// key, sequence number, uuid, properties and body.
+// An empty body deletes the key.
+type KVMsg struct {
+	Key      string
+	Sequence int64
+	UUID     []byte
+	Props    map[string]string
+	Body     []byte
+}
+
+// NewKVMsg creates a key-value message with a fresh uuid
+func NewKVMsg(key string, body []byte) *KVMsg {
+	uuid := make([]byte, 16)
+	rand.Read(uuid)
+	return &KVMsg{Key: key, UUID: uuid, Props: map[string]string{}, Body: body}
+}
+
+// Frames encodes the message as a multipart message
+func (m *KVMsg) Frames() [][]byte {
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, uint64(m.Sequence))
+	// Properties are sorted to get a stable encoding
+	names := make([]string, 0, len(m.Props))
+	for name := range m.Props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var props bytes.Buffer
+	for _, name := range names {
+		props.WriteString(name)
+		props.WriteByte('=')
+		props.WriteString(m.Props[name])
+		props.WriteByte('\n')
+	}
+	return [][]byte{[]byte(m.Key), seq, m.UUID, props.Bytes(), m.Body}
+}
+
+// DecodeKVMsg builds a key-value message from the frames of a multipart message
+func DecodeKVMsg(frames [][]byte) (*KVMsg, error) {
+	if len(frames) != kvFrames || len(frames[1]) != 8 {
+		return nil, ErrMalformedMessage
+	}
+	m := &KVMsg{
+		Key:      string(frames[0]),
+		Sequence: int64(binary.BigEndian.Uint64(frames[1])),
+		UUID:     append([]byte(nil), frames[2]...),
+		Props:    map[string]string{},
+		Body:     append([]byte(nil), frames[4]...),
+	}
+	for _, line := range strings.Split(string(frames[3]), "\n") {
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrMalformedMessage
+		}
+		m.Props[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+// Send the message to the socket
+func (m *KVMsg) Send(soc *zmq.Socket) error {
+	return soc.SendMultipart(m.Frames(), 0)
+}
+
+// RecvKVMsg receives a key-value message from the socket
+func RecvKVMsg(soc *zmq.Socket) (*KVMsg, error) {
+	msg, err := soc.RecvMultipart(0)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Close()
+	return DecodeKVMsg(msg.Data)
+}
@@ -0,0 +1,261 @@
+package clone
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// ErrNoSnapshot is returned when the server did not send a snapshot in time
+var ErrNoSnapshot = errors.New("clone: no snapshot received from server")
+
+// Default settings of the client map
+const (
+	DefaultTimeout = 3 * DefaultHeartbeatInterval
+	// Delay between two checks of the closing of the map
+	pollInterval = 100 * time.Millisecond
+)
+
+// Map is a client copy of the server key-value map restricted to a subtree.
+// It is initialized from a snapshot and kept in sync with the published
+// updates. The map is resynchronized when the server is silent for longer
+// than the timeout or when the server restarted.
+type Map struct {
+	// Timeout is the time of silence after which the server is considered dead
+	Timeout time.Duration
+
+	ctx              *zmq.Context
+	snapshotEndpoint string
+	subtree          string
+
+	mu       sync.RWMutex
+	kvmap    map[string]*KVMsg
+	sequence int64
+
+	pushMu     sync.Mutex
+	snapshot   *zmq.Socket
+	subscriber *zmq.Socket
+	publisher  *zmq.Socket
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+// NewMap creates a map of the given subtree, an empty subtree replicates
+// all keys. The snapshot is fetched before returning.
+func NewMap(ctx *zmq.Context, snapshotEndpoint, subscriberEndpoint, collectorEndpoint, subtree string) (*Map, error) {
+	m := &Map{
+		Timeout:          DefaultTimeout,
+		ctx:              ctx,
+		snapshotEndpoint: snapshotEndpoint,
+		subtree:          subtree,
+		kvmap:            map[string]*KVMsg{},
+	}
+	err := m.connect(subscriberEndpoint, collectorEndpoint)
+	if err != nil {
+		m.closeSockets()
+		return nil, err
+	}
+	// Subscribe before the snapshot so no update is lost in between
+	err = m.sync()
+	if err != nil {
+		m.closeSockets()
+		return nil, err
+	}
+	m.done = make(chan struct{})
+	m.stopped = make(chan struct{})
+	go m.run()
+	return m, nil
+}
+
+func (m *Map) connect(subscriberEndpoint, collectorEndpoint string) error {
+	var err error
+	m.subscriber, err = m.ctx.NewSocket(zmq.Sub)
+	if err != nil {
+		return err
+	}
+	err = m.subscriber.SetOptionString(zmq.Subscribe, &m.subtree)
+	if err != nil {
+		return err
+	}
+	hugz := heartbeatKey
+	err = m.subscriber.SetOptionString(zmq.Subscribe, &hugz)
+	if err != nil {
+		return err
+	}
+	err = m.subscriber.Connect(subscriberEndpoint)
+	if err != nil {
+		return err
+	}
+	m.publisher, err = m.ctx.NewSocket(zmq.Push)
+	if err != nil {
+		return err
+	}
+	return m.publisher.Connect(collectorEndpoint)
+}
+
+// Request a snapshot of the subtree and replace the map content with it.
+// The snapshot socket is recreated on timeout to discard a late answer.
+func (m *Map) sync() error {
+	if m.snapshot == nil {
+		soc, err := m.ctx.NewSocket(zmq.Dealer)
+		if err != nil {
+			return err
+		}
+		m.snapshot = soc
+		err = soc.Connect(m.snapshotEndpoint)
+		if err != nil {
+			return err
+		}
+	}
+	err := m.snapshot.SendMultipart([][]byte{[]byte(snapshotRequest), []byte(m.subtree)}, 0)
+	if err != nil {
+		return err
+	}
+	kvmap := map[string]*KVMsg{}
+	items := zmq.PollItems{&zmq.PollItem{Socket: m.snapshot, Events: zmq.Pollin}}
+	for {
+		count, err := items.Poll(m.Timeout)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			m.snapshot.SetOptionInt(zmq.Linger, 0)
+			m.snapshot.Close()
+			m.snapshot = nil
+			return ErrNoSnapshot
+		}
+		msg, err := RecvKVMsg(m.snapshot)
+		if err != nil {
+			return err
+		}
+		if msg.Key == snapshotEnd {
+			m.mu.Lock()
+			m.kvmap = kvmap
+			m.sequence = msg.Sequence
+			m.mu.Unlock()
+			return nil
+		}
+		kvmap[msg.Key] = msg
+	}
+}
+
+func (m *Map) run() {
+	defer close(m.stopped)
+	lastSeen := time.Now()
+	items := zmq.PollItems{&zmq.PollItem{Socket: m.subscriber, Events: zmq.Pollin}}
+	for {
+		select {
+		case <-m.done:
+			return
+		default:
+		}
+		_, err := items.Poll(pollInterval)
+		if err != nil {
+			return
+		}
+		if items[0].REvents&zmq.Pollin == 0 {
+			if time.Since(lastSeen) > m.Timeout {
+				// Server is silent, try to get a fresh snapshot
+				// and retry on next timeout on failure
+				m.sync()
+				lastSeen = time.Now()
+			}
+			continue
+		}
+		msg, err := RecvKVMsg(m.subscriber)
+		if err == ErrMalformedMessage {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		lastSeen = time.Now()
+		m.apply(msg)
+	}
+}
+
+// Apply an update received from the server
+func (m *Map) apply(msg *KVMsg) {
+	m.mu.Lock()
+	sequence := m.sequence
+	if msg.Key != heartbeatKey && msg.Sequence > sequence {
+		m.sequence = msg.Sequence
+		if len(msg.Body) == 0 {
+			delete(m.kvmap, msg.Key)
+		} else {
+			m.kvmap[msg.Key] = msg
+		}
+	}
+	m.mu.Unlock()
+	if msg.Key == heartbeatKey && msg.Sequence < sequence {
+		// The sequence went backward, the server restarted
+		m.sync()
+	}
+}
+
+// Get returns the value of a key
+func (m *Map) Get(key string) ([]byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	msg, ok := m.kvmap[key]
+	if !ok {
+		return nil, false
+	}
+	return msg.Body, true
+}
+
+// Keys returns the sorted list of keys of the map
+func (m *Map) Keys() []string {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.kvmap))
+	for key := range m.kvmap {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// Set sends an update of the key to the server.
+// The key expires after the given ttl unless ttl is 0.
+// The update is visible in the map once published back by the server.
+func (m *Map) Set(key string, value []byte, ttl time.Duration) error {
+	msg := NewKVMsg(key, value)
+	if ttl > 0 {
+		msg.Props[ttlProperty] = strconv.FormatFloat(ttl.Seconds(), 'f', -1, 64)
+	}
+	m.pushMu.Lock()
+	defer m.pushMu.Unlock()
+	return msg.Send(m.publisher)
+}
+
+// Delete sends the deletion of the key to the server
+func (m *Map) Delete(key string) error {
+	return m.Set(key, nil, 0)
+}
+
+// Close stops the synchronization and closes the map sockets
+func (m *Map) Close() error {
+	close(m.done)
+	<-m.stopped
+	return m.closeSockets()
+}
+
+func (m *Map) closeSockets() error {
+	var err error
+	for _, soc := range []*zmq.Socket{m.snapshot, m.subscriber, m.publisher} {
+		if soc == nil {
+			continue
+		}
+		soc.SetOptionInt(zmq.Linger, 0)
+		cerr := soc.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+package clone
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// Commands and special keys of the Clone protocol
+const (
+	snapshotRequest = "ICANHAZ?"
+	snapshotEnd     = "KTHXBAI"
+	heartbeatKey    = "HUGZ"
+	// ttlProperty holds the time to live of a key in seconds
+	ttlProperty = "ttl"
+)
+
+// DefaultHeartbeatInterval is the default delay between two heartbeats
+// published by the server
+const DefaultHeartbeatInterval = time.Second
+
+// A stored key-value message with its expiration date
+type entry struct {
+	msg    *KVMsg
+	expiry time.Time
+}
+
+// Server holds the reference key-value map.
+// It serves snapshots on a router socket, collects updates on a pull socket
+// and publishes sequenced updates on a pub socket.
+type Server struct {
+	// HeartbeatInterval is the delay between two heartbeats
+	HeartbeatInterval time.Duration
+
+	snapshot  *zmq.Socket
+	publisher *zmq.Socket
+	collector *zmq.Socket
+	kvmap     map[string]*entry
+	sequence  int64
+	err       error
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// NewServer creates a server with its snapshot, publisher and collector
+// sockets bound to the given endpoints
+func NewServer(ctx *zmq.Context, snapshotEndpoint, publisherEndpoint, collectorEndpoint string) (*Server, error) {
+	s := &Server{
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		kvmap:             map[string]*entry{},
+	}
+	sockets := []struct {
+		soc      **zmq.Socket
+		tp       zmq.SocketType
+		endpoint string
+	}{
+		{&s.snapshot, zmq.Router, snapshotEndpoint},
+		{&s.publisher, zmq.Pub, publisherEndpoint},
+		{&s.collector, zmq.Pull, collectorEndpoint},
+	}
+	for _, v := range sockets {
+		soc, err := ctx.NewSocket(v.tp)
+		if err != nil {
+			s.closeSockets()
+			return nil, err
+		}
+		*v.soc = soc
+		err = soc.Bind(v.endpoint)
+		if err != nil {
+			s.closeSockets()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Start serving snapshots and updates in a new goroutine
+func (s *Server) Start() {
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run()
+}
+
+// Close stops the server and closes its sockets.
+// The error which interrupted the server, if any, is returned.
+func (s *Server) Close() error {
+	if s.done != nil {
+		close(s.done)
+		<-s.stopped
+	}
+	err := s.closeSockets()
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+func (s *Server) closeSockets() error {
+	var err error
+	for _, soc := range []*zmq.Socket{s.snapshot, s.publisher, s.collector} {
+		if soc == nil {
+			continue
+		}
+		soc.SetOptionInt(zmq.Linger, 0)
+		cerr := soc.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *Server) run() {
+	defer close(s.stopped)
+	heartbeatAt := time.Now().Add(s.HeartbeatInterval)
+	items := zmq.PollItems{
+		&zmq.PollItem{Socket: s.snapshot, Events: zmq.Pollin},
+		&zmq.PollItem{Socket: s.collector, Events: zmq.Pollin},
+	}
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		timeout := heartbeatAt.Sub(time.Now())
+		if timeout < 0 {
+			timeout = 0
+		}
+		_, err := items.Poll(timeout)
+		if err != nil {
+			s.err = err
+			return
+		}
+		if items[0].REvents&zmq.Pollin != 0 {
+			err = s.sendSnapshot()
+			if err != nil {
+				s.err = err
+				return
+			}
+		}
+		if items[1].REvents&zmq.Pollin != 0 {
+			err = s.collect()
+			if err != nil {
+				s.err = err
+				return
+			}
+		}
+		err = s.flushTTL()
+		if err != nil {
+			s.err = err
+			return
+		}
+		if !time.Now().Before(heartbeatAt) {
+			hugz := &KVMsg{Key: heartbeatKey, Sequence: s.sequence}
+			err = hugz.Send(s.publisher)
+			if err != nil {
+				s.err = err
+				return
+			}
+			heartbeatAt = time.Now().Add(s.HeartbeatInterval)
+		}
+	}
+}
+
+// Answer a snapshot request with all keys of the requested subtree
+func (s *Server) sendSnapshot() error {
+	msg, err := s.snapshot.RecvMultipart(0)
+	if err != nil {
+		return err
+	}
+	defer msg.Close()
+	if len(msg.Data) != 3 || string(msg.Data[1]) != snapshotRequest {
+		return nil
+	}
+	identity := msg.Data[0]
+	subtree := string(msg.Data[2])
+	for key, e := range s.kvmap {
+		if !strings.HasPrefix(key, subtree) {
+			continue
+		}
+		frames := append([][]byte{identity}, e.msg.Frames()...)
+		err = s.snapshot.SendMultipart(frames, 0)
+		if err != nil {
+			return err
+		}
+	}
+	end := &KVMsg{Key: snapshotEnd, Sequence: s.sequence, Body: []byte(subtree)}
+	return s.snapshot.SendMultipart(append([][]byte{identity}, end.Frames()...), 0)
+}
+
+// Sequence and publish an update received from a client
+func (s *Server) collect() error {
+	msg, err := RecvKVMsg(s.collector)
+	if err == ErrMalformedMessage {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.sequence++
+	msg.Sequence = s.sequence
+	err = msg.Send(s.publisher)
+	if err != nil {
+		return err
+	}
+	if len(msg.Body) == 0 {
+		delete(s.kvmap, msg.Key)
+		return nil
+	}
+	e := &entry{msg: msg}
+	ttl, err := strconv.ParseFloat(msg.Props[ttlProperty], 64)
+	if err == nil && ttl > 0 {
+		e.expiry = time.Now().Add(time.Duration(ttl * float64(time.Second)))
+	}
+	s.kvmap[msg.Key] = e
+	return nil
+}
+
+// Delete expired keys and publish their deletion
+func (s *Server) flushTTL() error {
+	now := time.Now()
+	for key, e := range s.kvmap {
+		if e.expiry.IsZero() || now.Before(e.expiry) {
+			continue
+		}
+		delete(s.kvmap, key)
+		s.sequence++
+		msg := NewKVMsg(key, nil)
+		msg.Sequence = s.sequence
+		err := msg.Send(s.publisher)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
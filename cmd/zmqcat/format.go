@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// format converts multipart messages from and to lines of text
+type format interface {
+	encode(frames [][]byte) (string, error)
+	decode(line string) ([][]byte, error)
+}
+
+// newFormat returns the format of the given name. Frames of the raw, hex
+// and z85 formats are separated by sep.
+func newFormat(name, sep string) (format, error) {
+	switch name {
+	case "raw":
+		return textFormat{sep: sep, enc: func(b []byte) (string, error) { return string(b), nil },
+			dec: func(s string) ([]byte, error) { return []byte(s), nil }}, nil
+	case "hex":
+		return textFormat{sep: sep, enc: func(b []byte) (string, error) { return hex.EncodeToString(b), nil },
+			dec: hex.DecodeString}, nil
+	case "z85":
+		return textFormat{sep: sep, enc: z85Encode, dec: z85Decode}, nil
+	case "json":
+		return jsonFormat{}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected raw, hex, json or z85", name)
+}
+
+// textFormat encodes every frame and joins them with a separator
+type textFormat struct {
+	sep string
+	enc func([]byte) (string, error)
+	dec func(string) ([]byte, error)
+}
+
+func (f textFormat) encode(frames [][]byte) (string, error) {
+	parts := make([]string, len(frames))
+	for i, frame := range frames {
+		part, err := f.enc(frame)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, f.sep), nil
+}
+
+func (f textFormat) decode(line string) ([][]byte, error) {
+	parts := []string{line}
+	if f.sep != "" {
+		parts = strings.Split(line, f.sep)
+	}
+	frames := make([][]byte, len(parts))
+	for i, part := range parts {
+		frame, err := f.dec(part)
+		if err != nil {
+			return nil, err
+		}
+		frames[i] = frame
+	}
+	return frames, nil
+}
+
+// jsonFormat writes a message as an array of frames. Text frames are
+// strings, binary frames are objects with a hex field.
+type jsonFormat struct{}
+
+type binaryFrame struct {
+	Hex string `json:"hex"`
+}
+
+func (jsonFormat) encode(frames [][]byte) (string, error) {
+	values := make([]interface{}, len(frames))
+	for i, frame := range frames {
+		if utf8.Valid(frame) {
+			values[i] = string(frame)
+		} else {
+			values[i] = binaryFrame{Hex: hex.EncodeToString(frame)}
+		}
+	}
+	data, err := json.Marshal(values)
+	return string(data), err
+}
+
+func (jsonFormat) decode(line string) ([][]byte, error) {
+	var values []json.RawMessage
+	err := json.Unmarshal([]byte(line), &values)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([][]byte, len(values))
+	for i, value := range values {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			frames[i] = []byte(s)
+			continue
+		}
+		var b binaryFrame
+		err = json.Unmarshal(value, &b)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d is neither a string nor a hex object", i)
+		}
+		frames[i], err = hex.DecodeString(b.Hex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return frames, nil
+}
+
+// Alphabet of the Z85 encoding defined by the zeromq RFC 32
+const z85Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ.-:+=^!/*?&<>()[]{}@%$#"
+
+var errZ85Size = errors.New("z85: size is not a multiple of 4 bytes")
+
+func z85Encode(data []byte) (string, error) {
+	if len(data)%4 != 0 {
+		return "", errZ85Size
+	}
+	var out strings.Builder
+	for i := 0; i < len(data); i += 4 {
+		value := uint32(data[i])<<24 | uint32(data[i+1])<<16 | uint32(data[i+2])<<8 | uint32(data[i+3])
+		var chunk [5]byte
+		for j := 4; j >= 0; j-- {
+			chunk[j] = z85Alphabet[value%85]
+			value /= 85
+		}
+		out.Write(chunk[:])
+	}
+	return out.String(), nil
+}
+
+func z85Decode(s string) ([]byte, error) {
+	if len(s)%5 != 0 {
+		return nil, errors.New("z85: size is not a multiple of 5 characters")
+	}
+	data := make([]byte, 0, len(s)/5*4)
+	for i := 0; i < len(s); i += 5 {
+		var value uint64
+		for j := 0; j < 5; j++ {
+			digit := strings.IndexByte(z85Alphabet, s[i+j])
+			if digit < 0 {
+				return nil, fmt.Errorf("z85: invalid character %q", s[i+j])
+			}
+			value = value*85 + uint64(digit)
+		}
+		if value > 0xffffffff {
+			return nil, errors.New("z85: value out of range")
+		}
+		data = append(data, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+	}
+	return data, nil
+}
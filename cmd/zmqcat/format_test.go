@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZ85(t *testing.T) {
+	// Test vector of the zeromq RFC 32
+	data := []byte{0x86, 0x4F, 0xD2, 0x6F, 0xB5, 0x59, 0xF7, 0x5B}
+	s, err := z85Encode(data)
+	if err != nil || s != "HelloWorld" {
+		t.Fatalf("Expected HelloWorld, got %s, err %v", s, err)
+	}
+	decoded, err := z85Decode(s)
+	if err != nil || !reflect.DeepEqual(decoded, data) {
+		t.Fatalf("Expected %x, got %x, err %v", data, decoded, err)
+	}
+	_, err = z85Encode([]byte("abc"))
+	if err != errZ85Size {
+		t.Fatalf("Expected size error, got %v", err)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	frames := [][]byte{[]byte("id"), {}, {0xff, 0, 1, 2}}
+	expected := map[string]string{
+		"raw":  "id||\xff\x00\x01\x02",
+		"hex":  "6964||ff000102",
+		"json": `["id","",{"hex":"ff000102"}]`,
+	}
+	for name, line := range expected {
+		f, err := newFormat(name, "|")
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := f.encode(frames)
+		if err != nil || encoded != line {
+			t.Fatalf("Expected %s line %q, got %q, err %v", name, line, encoded, err)
+		}
+		decoded, err := f.decode(line)
+		if err != nil || !reflect.DeepEqual(decoded, frames) {
+			t.Fatalf("Expected %s frames %q, got %q, err %v", name, frames, decoded, err)
+		}
+	}
+	_, err := newFormat("xml", "")
+	if err == nil {
+		t.Fatal("Expected unknown format error")
+	}
+}
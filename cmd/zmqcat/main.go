@@ -0,0 +1,441 @@
+// Command zmqcat connects stdin and stdout to a zmq socket.
+//
+// Usage:
+//
+//	zmqcat -type pull -bind tcp://*:5555
+//	echo hello | zmqcat -type push -connect tcp://127.0.0.1:5555
+//	zmqcat -type sub -connect tcp://127.0.0.1:5556 -subscribe topic -format hex
+//
+// Every line of stdin is a message whose frames are separated by the
+// separator, which is a tab by default. Received messages are printed one
+// per line in the same format: raw, hex, json or z85.
+//
+// Push and pub sockets send the lines of stdin, pull, sub, xpub and xsub
+// sockets print the received messages. Req sockets send every line and
+// print the reply. Rep sockets print every request and reply with the next
+// line of stdin, or with the request itself with -echo. Dealer, router and
+// pair sockets send the lines of stdin while printing the received messages.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+var socketTypes = map[string]zmq.SocketType{
+	"req":    zmq.Req,
+	"rep":    zmq.Rep,
+	"dealer": zmq.Dealer,
+	"router": zmq.Router,
+	"pub":    zmq.Pub,
+	"sub":    zmq.Sub,
+	"xpub":   zmq.Xpub,
+	"xsub":   zmq.Xsub,
+	"push":   zmq.Push,
+	"pull":   zmq.Pull,
+	"pair":   zmq.Pair,
+}
+
+// Options which can be set with -option name=value
+var (
+	intOptions = map[string]zmq.SocketOptionInt{
+		"linger":                  zmq.Linger,
+		"sndhwm":                  zmq.Sndhwm,
+		"rcvhwm":                  zmq.Rcvhwm,
+		"sndtimeo":                zmq.Sndtimeo,
+		"rcvtimeo":                zmq.Rcvtimeo,
+		"sndbuf":                  zmq.Sndbuf,
+		"rcvbuf":                  zmq.Rcvbuf,
+		"reconnect-ivl":           zmq.ReconnectIvl,
+		"reconnect-ivl-max":       zmq.ReconnectIvlMax,
+		"backlog":                 zmq.Backlog,
+		"ipv4only":                zmq.Ipv4only,
+		"delay-attach-on-connect": zmq.DelayAttachOnConnect,
+		"tcp-keepalive":           zmq.TcpKeepalive,
+		"router-mandatory":        zmq.RouterMandatory,
+		"xpub-verbose":            zmq.XpubVerbose,
+		"plain-server":            zmq.PlainServer,
+	}
+	int64Options = map[string]zmq.SocketOptionInt64{
+		"maxmsgsize": zmq.Maxmsgsize,
+	}
+	stringOptions = map[string]zmq.SocketOptionString{
+		"identity":       zmq.Identity,
+		"plain-username": zmq.PlainUsername,
+		"plain-password": zmq.PlainPassword,
+	}
+)
+
+// Interval between two polls of the socket while reading stdin
+const pollInterval = 10 * time.Millisecond
+
+// errTimeout stops the command when no message is received in time
+var errTimeout = errors.New("timeout")
+
+// listFlag is a flag which can be repeated
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// config holds the command line flags
+type config struct {
+	socketType string
+	binds      listFlag
+	connects   listFlag
+	subscribes listFlag
+	options    listFlag
+	format     string
+	separator  string
+	echo       bool
+	count      int
+	wait       time.Duration
+	timeout    time.Duration
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.socketType, "type", "", "socket type: req, rep, dealer, router, pub, sub, xpub, xsub, push, pull or pair")
+	flag.Var(&cfg.binds, "bind", "endpoint to bind, can be repeated")
+	flag.Var(&cfg.connects, "connect", "endpoint to connect, can be repeated")
+	flag.Var(&cfg.subscribes, "subscribe", "subscription of a sub socket, can be repeated, all messages by default")
+	flag.Var(&cfg.options, "option", "socket option as name=value, like linger=0 or identity=client, can be repeated")
+	flag.StringVar(&cfg.format, "format", "raw", "format of the messages: raw, hex, json or z85")
+	flag.StringVar(&cfg.separator, "separator", "\t", "separator of the frames of a message in raw, hex and z85 formats")
+	flag.BoolVar(&cfg.echo, "echo", false, "reply to requests with the request itself on a rep socket")
+	flag.IntVar(&cfg.count, "count", 0, "exit after count messages, sent by push and pub sockets and received otherwise, 0 for no limit")
+	flag.DurationVar(&cfg.wait, "wait", 0, "wait before sending the first message, to let subscribers connect")
+	flag.DurationVar(&cfg.timeout, "timeout", 0, "exit when no message is received for the duration, 0 to wait forever")
+	flag.Parse()
+	if cfg.socketType == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(cfg, os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run creates the socket and exchanges messages until stdin is consumed,
+// the count of messages is reached or the timeout expires
+func run(cfg config, in io.Reader, out io.Writer) error {
+	socketType, ok := socketTypes[strings.ToLower(cfg.socketType)]
+	if !ok {
+		return fmt.Errorf("unknown socket type %q", cfg.socketType)
+	}
+	if len(cfg.binds)+len(cfg.connects) == 0 {
+		return errors.New("no endpoint, use -bind or -connect")
+	}
+	f, err := newFormat(cfg.format, cfg.separator)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		return err
+	}
+	defer ctx.Destroy()
+	soc, err := ctx.NewSocket(socketType)
+	if err != nil {
+		return err
+	}
+	defer soc.Close()
+	for _, option := range cfg.options {
+		err = setOption(soc, option)
+		if err != nil {
+			return err
+		}
+	}
+	if socketType == zmq.Sub {
+		if len(cfg.subscribes) == 0 {
+			cfg.subscribes = listFlag{""}
+		}
+		for _, prefix := range cfg.subscribes {
+			err = soc.Subscribe([]byte(prefix))
+			if err != nil {
+				return fmt.Errorf("subscribe %q: %w", prefix, err)
+			}
+		}
+	}
+	for _, endpoint := range cfg.binds {
+		err = soc.Bind(endpoint)
+		if err != nil {
+			return fmt.Errorf("bind %s: %w", endpoint, err)
+		}
+	}
+	for _, endpoint := range cfg.connects {
+		err = soc.Connect(endpoint)
+		if err != nil {
+			return fmt.Errorf("connect %s: %w", endpoint, err)
+		}
+	}
+
+	c := &cat{config: cfg, socket: soc, format: f, in: bufio.NewReader(in), out: out}
+	switch socketType {
+	case zmq.Push, zmq.Pub:
+		err = c.send()
+	case zmq.Pull, zmq.Sub, zmq.Xpub, zmq.Xsub:
+		err = c.recv()
+	case zmq.Req:
+		err = c.request()
+	case zmq.Rep:
+		err = c.reply()
+	default:
+		err = c.both()
+	}
+	if err == io.EOF || err == errTimeout {
+		return nil
+	}
+	return err
+}
+
+// setOption sets a socket option given as name=value
+func setOption(soc *zmq.Socket, option string) error {
+	name, value, ok := strings.Cut(option, "=")
+	if !ok {
+		return fmt.Errorf("invalid option %q, expected name=value", option)
+	}
+	name = strings.ToLower(name)
+	var err error
+	if o, ok := intOptions[name]; ok {
+		var v int
+		v, err = strconv.Atoi(value)
+		if err == nil {
+			err = soc.SetOptionInt(o, v)
+		}
+	} else if o, ok := int64Options[name]; ok {
+		var v int64
+		v, err = strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			err = soc.SetOptionInt64(o, v)
+		}
+	} else if o, ok := stringOptions[name]; ok {
+		err = soc.SetOptionString(o, &value)
+	} else {
+		return fmt.Errorf("unknown option %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("option %s: %w", name, err)
+	}
+	return nil
+}
+
+// cat moves messages between the socket and stdin and stdout
+type cat struct {
+	config
+	socket *zmq.Socket
+	format format
+	in     *bufio.Reader
+	out    io.Writer
+	// Number of messages sent or received
+	messages int
+	waited   bool
+}
+
+// readLine returns the next line of stdin without its line ending
+func (c *cat) readLine() (string, error) {
+	line, err := c.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// sendLine decodes a line and sends it as a multipart message
+func (c *cat) sendLine(line string) error {
+	frames, err := c.format.decode(line)
+	if err != nil {
+		return fmt.Errorf("invalid message %q: %w", line, err)
+	}
+	if !c.waited {
+		time.Sleep(c.wait)
+		c.waited = true
+	}
+	return c.socket.SendMultipart(frames, 0)
+}
+
+// receive waits for a message and prints it
+func (c *cat) receive(timeout time.Duration) ([][]byte, error) {
+	if timeout > 0 {
+		items := zmq.PollItems{&zmq.PollItem{Socket: c.socket, Events: zmq.Pollin}}
+		n, err := items.Poll(timeout)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, errTimeout
+		}
+	}
+	msg, err := c.socket.RecvMultipart(0)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([][]byte, len(msg.Data))
+	for i, frame := range msg.Data {
+		frames[i] = append([]byte(nil), frame...)
+	}
+	msg.Close()
+	line, err := c.format.encode(frames)
+	if err != nil {
+		log.Printf("can not print message of %d frames: %s", len(frames), err)
+		return frames, nil
+	}
+	_, err = fmt.Fprintln(c.out, line)
+	return frames, err
+}
+
+// done counts a message and tells if the count is reached
+func (c *cat) done() bool {
+	c.messages++
+	return c.count > 0 && c.messages >= c.count
+}
+
+// send sends the lines of stdin
+func (c *cat) send() error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		err = c.sendLine(line)
+		if err != nil {
+			return err
+		}
+		if c.done() {
+			return nil
+		}
+	}
+}
+
+// recv prints the received messages
+func (c *cat) recv() error {
+	for {
+		_, err := c.receive(c.timeout)
+		if err != nil {
+			return err
+		}
+		if c.done() {
+			return nil
+		}
+	}
+}
+
+// request sends the lines of stdin and prints the replies
+func (c *cat) request() error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		err = c.sendLine(line)
+		if err != nil {
+			return err
+		}
+		_, err = c.receive(c.timeout)
+		if err != nil {
+			return err
+		}
+		if c.done() {
+			return nil
+		}
+	}
+}
+
+// reply prints the requests and replies with the lines of stdin
+func (c *cat) reply() error {
+	for {
+		frames, err := c.receive(c.timeout)
+		if err != nil {
+			return err
+		}
+		if c.echo {
+			err = c.socket.SendMultipart(frames, 0)
+		} else {
+			var line string
+			line, err = c.readLine()
+			if err == nil {
+				err = c.sendLine(line)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if c.done() {
+			return nil
+		}
+	}
+}
+
+// both sends the lines of stdin while printing the received messages.
+// Stdin is read by another goroutine as sockets are not thread safe.
+func (c *cat) both() error {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			line, err := c.readLine()
+			if err != nil {
+				errs <- err
+				close(lines)
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	last := time.Now()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if err := <-errs; err != io.EOF {
+					return err
+				}
+				lines = nil
+				break
+			}
+			err := c.sendLine(line)
+			if err != nil {
+				return err
+			}
+		default:
+		}
+		_, err := c.receive(pollInterval)
+		if err == errTimeout {
+			if c.timeout > 0 && time.Since(last) > c.timeout {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		last = time.Now()
+		if c.done() {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// bindPeer binds a socket the command connects to
+func bindPeer(t *testing.T, socketType zmq.SocketType) (*zmq.Socket, string) {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	soc, err := ctx.NewSocket(socketType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soc.SetOptionInt(zmq.Linger, 0)
+	err = soc.Bind("tcp://127.0.0.1:*")
+	if err != nil {
+		t.Fatal("Error on bind", err)
+	}
+	t.Cleanup(func() {
+		soc.Close()
+		ctx.Destroy()
+	})
+	endpoint, _ := soc.GetOptionString(zmq.LastEndpoint)
+	return soc, endpoint
+}
+
+func TestCatPush(t *testing.T) {
+	pull, endpoint := bindPeer(t, zmq.Pull)
+	cfg := config{socketType: "push", connects: listFlag{endpoint}, format: "raw", separator: "\t"}
+	err := run(cfg, strings.NewReader("hello\tworld\nlast"), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal("Error on run", err)
+	}
+	expected := [][][]byte{
+		{[]byte("hello"), []byte("world")},
+		{[]byte("last")},
+	}
+	for _, frames := range expected {
+		msg, err := pull.RecvMultipart(0)
+		if err != nil {
+			t.Fatal("Error on receive", err)
+		}
+		if !reflect.DeepEqual(msg.Data, frames) {
+			t.Fatalf("Expected %q, got %q", frames, msg.Data)
+		}
+	}
+}
+
+func TestCatPull(t *testing.T) {
+	push, endpoint := bindPeer(t, zmq.Push)
+	// Push sockets block until a peer is connected
+	go func() {
+		push.SendMultipart([][]byte{[]byte("a"), {0xff}}, 0)
+		push.SendMultipart([][]byte{[]byte("b")}, 0)
+	}()
+	var out bytes.Buffer
+	cfg := config{socketType: "pull", connects: listFlag{endpoint}, format: "json", count: 2}
+	err := run(cfg, strings.NewReader(""), &out)
+	if err != nil {
+		t.Fatal("Error on run", err)
+	}
+	expected := "[\"a\",{\"hex\":\"ff\"}]\n[\"b\"]\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestCatRequest(t *testing.T) {
+	rep, endpoint := bindPeer(t, zmq.Rep)
+	go func() {
+		for {
+			msg, err := rep.RecvMultipart(0)
+			if err != nil {
+				return
+			}
+			rep.SendMultipart(append(msg.Data, []byte("ok")), 0)
+		}
+	}()
+	var out bytes.Buffer
+	cfg := config{socketType: "REQ", connects: listFlag{endpoint}, format: "hex", separator: " ",
+		options: listFlag{"linger=0"}}
+	err := run(cfg, strings.NewReader("01 02\n03\n"), &out)
+	if err != nil {
+		t.Fatal("Error on run", err)
+	}
+	expected := "01 02 6f6b\n03 6f6b\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestCatTimeout(t *testing.T) {
+	_, endpoint := bindPeer(t, zmq.Pub)
+	cfg := config{socketType: "sub", connects: listFlag{endpoint}, format: "raw", timeout: 50 * time.Millisecond}
+	start := time.Now()
+	err := run(cfg, strings.NewReader(""), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal("Error on run", err)
+	}
+	if time.Since(start) < cfg.timeout {
+		t.Fatal("Expected run to wait for the timeout")
+	}
+}
+
+func TestCatErrors(t *testing.T) {
+	configs := []config{
+		{socketType: "stream", binds: listFlag{"inproc://cat"}, format: "raw"},
+		{socketType: "pull", format: "raw"},
+		{socketType: "pull", binds: listFlag{"inproc://cat"}, format: "raw", options: listFlag{"color=blue"}},
+		{socketType: "pull", binds: listFlag{"inproc://cat"}, format: "raw", options: listFlag{"linger"}},
+	}
+	for _, cfg := range configs {
+		err := run(cfg, strings.NewReader(""), &bytes.Buffer{})
+		if err == nil {
+			t.Fatalf("Expected error for %+v", cfg)
+		}
+	}
+}
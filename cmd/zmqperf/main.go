@@ -0,0 +1,121 @@
+// Command zmqperf measures the latency and the throughput of sockets,
+// like the local_lat, remote_lat, local_thr and remote_thr tools of libzmq.
+//
+// Usage:
+//
+//	zmqperf [-copy] local_lat <bind-to> <message-size> <roundtrip-count>
+//	zmqperf [-copy] remote_lat <connect-to> <message-size> <roundtrip-count>
+//	zmqperf [-copy] local_thr <bind-to> <message-size> <message-count>
+//	zmqperf [-copy] remote_thr <connect-to> <message-size> <message-count>
+//	zmqperf [-copy] lat <endpoint> <message-size> <roundtrip-count>
+//	zmqperf [-copy] thr <endpoint> <message-size> <message-count>
+//
+// The local and remote tests run in different processes, remote_lat and
+// local_thr print the results. The lat and thr tests run both sides in
+// the same process and context, which allows testing the inproc transport
+// and comparing it with tcp and ipc.
+//
+// Latencies are one way latencies, half of the round trips, with their
+// percentiles. By default messages are sent and received with zero-copy,
+// -copy has the zmq library copy sent messages and copies received ones
+// to buffers managed by the garbage collector.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+func main() {
+	copyMode := flag.Bool("copy", false, "copy sent and received messages instead of using zero-copy")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zmqperf [-copy] local_lat|remote_lat|local_thr|remote_thr|lat|thr <endpoint> <message-size> <count>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 4 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	size, err := strconv.Atoi(flag.Arg(2))
+	if err != nil || size < 0 {
+		log.Fatalf("invalid message size %q", flag.Arg(2))
+	}
+	count, err := strconv.Atoi(flag.Arg(3))
+	if err != nil || count <= 0 {
+		log.Fatalf("invalid count %q", flag.Arg(3))
+	}
+
+	t := test{endpoint: flag.Arg(1), size: size, count: count, copy: *copyMode}
+	err = run(os.Stdout, flag.Arg(0), t)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs a test in a new context and prints its results
+func run(w io.Writer, name string, t test) error {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		return err
+	}
+	defer ctx.Destroy()
+
+	switch name {
+	case "local_lat":
+		return localLat(ctx, t)
+	case "remote_lat":
+		res, err := remoteLat(ctx, t)
+		if err != nil {
+			return err
+		}
+		res.print(w)
+	case "local_thr":
+		res, err := localThr(ctx, t)
+		if err != nil {
+			return err
+		}
+		res.print(w)
+	case "remote_thr":
+		return remoteThr(ctx, t)
+	case "lat":
+		errs := make(chan error, 1)
+		go func() { errs <- localLat(ctx, t) }()
+		res, err := remoteLat(ctx, t)
+		if err != nil {
+			return err
+		}
+		err = <-errs
+		if err != nil {
+			return err
+		}
+		res.print(w)
+	case "thr":
+		results := make(chan throughput, 1)
+		errs := make(chan error, 1)
+		go func() {
+			res, err := localThr(ctx, t)
+			results <- res
+			errs <- err
+		}()
+		err = remoteThr(ctx, t)
+		if err != nil {
+			return err
+		}
+		res := <-results
+		err = <-errs
+		if err != nil {
+			return err
+		}
+		res.print(w)
+	default:
+		return fmt.Errorf("unknown test %q", name)
+	}
+	return nil
+}
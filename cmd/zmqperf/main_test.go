@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bonnefoa/go-zeromq/zmqtest"
+)
+
+func TestLatency(t *testing.T) {
+	for _, endpoint := range []string{"inproc://perf_lat", zmqtest.TCPEndpoint(t)} {
+		var out bytes.Buffer
+		err := run(&out, "lat", test{endpoint: endpoint, size: 100, count: 200})
+		if err != nil {
+			t.Fatal("Error on latency test", endpoint, err)
+		}
+		for _, line := range []string{"roundtrip count: 200", "mode: zero-copy", "latency p99: ", "latency max: "} {
+			if !strings.Contains(out.String(), line) {
+				t.Fatalf("Expected %q in results:\n%s", line, out.String())
+			}
+		}
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	var out bytes.Buffer
+	err := run(&out, "thr", test{endpoint: "inproc://perf_thr", size: 1000, count: 1000, copy: true})
+	if err != nil {
+		t.Fatal("Error on throughput test", err)
+	}
+	for _, line := range []string{"message count: 1000", "mode: copy", "[msg/s]", "[MB/s]"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("Expected %q in results:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestUnknownTest(t *testing.T) {
+	err := run(&bytes.Buffer{}, "local_foo", test{endpoint: "inproc://perf", size: 1, count: 1})
+	if err == nil {
+		t.Fatal("Expected error on unknown test")
+	}
+}
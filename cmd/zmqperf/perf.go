@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// Highest latency recorded by the histograms, in nanoseconds
+const maxLatency = int64(time.Minute)
+
+// Percentiles of the latency printed by the latency tests
+var percentiles = []float64{50, 90, 99, 99.9}
+
+// test holds the parameters shared by the local and remote sides
+type test struct {
+	endpoint string
+	size     int
+	count    int
+	// copy sends messages copied by the zmq library and copies received
+	// messages to buffers managed by the garbage collector, instead of
+	// using zero-copy
+	copy bool
+}
+
+// latency is the result of a latency test
+type latency struct {
+	test
+	elapsed time.Duration
+	// One way latencies in nanoseconds
+	histogram *hdrhistogram.Histogram
+}
+
+// throughput is the result of a throughput test
+type throughput struct {
+	test
+	elapsed time.Duration
+}
+
+// socket creates a socket bound or connected to the endpoint of the test
+func (t test) socket(ctx *zmq.Context, socketType zmq.SocketType, bind bool) (*zmq.Socket, error) {
+	soc, err := ctx.NewSocket(socketType)
+	if err != nil {
+		return nil, err
+	}
+	if bind {
+		err = soc.Bind(t.endpoint)
+	} else {
+		err = soc.Connect(t.endpoint)
+	}
+	if err != nil {
+		soc.Close()
+		return nil, err
+	}
+	return soc, nil
+}
+
+// send sends the payload, copied by the zmq library in copy mode
+func (t test) send(soc *zmq.Socket, payload []byte) error {
+	if t.copy {
+		return soc.SendCopy(payload, 0)
+	}
+	return soc.Send(payload, 0)
+}
+
+// recv receives a message and checks its size. The data is copied before
+// the message is closed in copy mode.
+func (t test) recv(soc *zmq.Socket) error {
+	msg, err := soc.Recv(0)
+	if err != nil {
+		return err
+	}
+	size := len(msg.Data)
+	if t.copy {
+		data := make([]byte, size)
+		copy(data, msg.Data)
+	}
+	msg.Close()
+	if size != t.size {
+		return fmt.Errorf("message of incorrect size received: %d bytes", size)
+	}
+	return nil
+}
+
+// localLat echoes the messages of remoteLat
+func localLat(ctx *zmq.Context, t test) error {
+	soc, err := t.socket(ctx, zmq.Rep, true)
+	if err != nil {
+		return err
+	}
+	defer soc.Close()
+	payload := make([]byte, t.size)
+	for i := 0; i < t.count; i++ {
+		err = t.recv(soc)
+		if err != nil {
+			return err
+		}
+		err = t.send(soc, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteLat measures the round trips of messages echoed by localLat
+func remoteLat(ctx *zmq.Context, t test) (latency, error) {
+	res := latency{test: t, histogram: hdrhistogram.New(1, maxLatency, 3)}
+	soc, err := t.socket(ctx, zmq.Req, false)
+	if err != nil {
+		return res, err
+	}
+	defer soc.Close()
+	payload := make([]byte, t.size)
+	start := time.Now()
+	for i := 0; i < t.count; i++ {
+		sent := time.Now()
+		err = t.send(soc, payload)
+		if err != nil {
+			return res, err
+		}
+		err = t.recv(soc)
+		if err != nil {
+			return res, err
+		}
+		res.histogram.RecordValue(int64(time.Since(sent)) / 2)
+	}
+	res.elapsed = time.Since(start)
+	return res, nil
+}
+
+// localThr receives the messages of remoteThr. The clock starts
+// with the first message, like libzmq local_thr.
+func localThr(ctx *zmq.Context, t test) (throughput, error) {
+	res := throughput{test: t}
+	soc, err := t.socket(ctx, zmq.Pull, true)
+	if err != nil {
+		return res, err
+	}
+	defer soc.Close()
+	err = t.recv(soc)
+	if err != nil {
+		return res, err
+	}
+	start := time.Now()
+	for i := 1; i < t.count; i++ {
+		err = t.recv(soc)
+		if err != nil {
+			return res, err
+		}
+	}
+	res.elapsed = time.Since(start)
+	return res, nil
+}
+
+// remoteThr sends messages to localThr as fast as possible
+func remoteThr(ctx *zmq.Context, t test) error {
+	soc, err := t.socket(ctx, zmq.Push, false)
+	if err != nil {
+		return err
+	}
+	defer soc.Close()
+	payload := make([]byte, t.size)
+	for i := 0; i < t.count; i++ {
+		err = t.send(soc, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mode describes the send path of a test
+func (t test) mode() string {
+	if t.copy {
+		return "copy"
+	}
+	return "zero-copy"
+}
+
+func (r latency) print(w io.Writer) {
+	fmt.Fprintf(w, "message size: %d [B]\n", r.size)
+	fmt.Fprintf(w, "roundtrip count: %d\n", r.count)
+	fmt.Fprintf(w, "mode: %s\n", r.mode())
+	fmt.Fprintf(w, "average latency: %.3f [us]\n", microseconds(r.elapsed)/float64(2*r.count))
+	for _, p := range percentiles {
+		fmt.Fprintf(w, "latency p%g: %.3f [us]\n", p, microseconds(time.Duration(r.histogram.ValueAtPercentile(p))))
+	}
+	fmt.Fprintf(w, "latency max: %.3f [us]\n", microseconds(time.Duration(r.histogram.Max())))
+}
+
+// rate returns the number of messages per second
+func (r throughput) rate() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.count) / r.elapsed.Seconds()
+}
+
+func (r throughput) print(w io.Writer) {
+	fmt.Fprintf(w, "message size: %d [B]\n", r.size)
+	fmt.Fprintf(w, "message count: %d\n", r.count)
+	fmt.Fprintf(w, "mode: %s\n", r.mode())
+	fmt.Fprintf(w, "mean throughput: %.0f [msg/s]\n", r.rate())
+	fmt.Fprintf(w, "mean throughput: %.3f [MB/s]\n", r.rate()*float64(r.size)/1e6)
+}
+
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Magic numbers of capture files
+var (
+	pcapMagics = [][]byte{
+		{0xd4, 0xc3, 0xb2, 0xa1}, {0xa1, 0xb2, 0xc3, 0xd4},
+		{0x4d, 0x3c, 0xb2, 0xa1}, {0xa1, 0xb2, 0x3c, 0x4d},
+	}
+	pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+)
+
+// stream is the reassembled data sent in one direction of a tcp connection
+type stream struct {
+	src, dst string
+	data     bytes.Buffer
+	// next is the sequence number of the next expected byte
+	next    uint32
+	started bool
+	// pending holds segments received ahead of next
+	pending map[uint32][]byte
+}
+
+// add appends a tcp segment, segments received out of order are kept
+// until the missing data arrives and retransmitted data is skipped
+func (s *stream) add(seq uint32, payload []byte) {
+	if !s.started {
+		s.started = true
+		s.next = seq
+	}
+	if len(payload) == 0 {
+		return
+	}
+	// Sequence numbers wrap around
+	diff := int32(seq - s.next)
+	if diff > 0 {
+		s.pending[seq] = append([]byte{}, payload...)
+		return
+	}
+	if -diff >= int32(len(payload)) {
+		return
+	}
+	payload = payload[-diff:]
+	s.data.Write(payload)
+	s.next += uint32(len(payload))
+	for {
+		segment, ok := s.pending[s.next]
+		if !ok {
+			return
+		}
+		delete(s.pending, s.next)
+		s.data.Write(segment)
+		s.next += uint32(len(segment))
+	}
+}
+
+// connection groups the streams of both directions
+type connection struct {
+	streams []*stream
+}
+
+// capture holds the tcp connections of a capture file in the order
+// they were first seen
+type capture struct {
+	connections []*connection
+	byKey       map[string]*connection
+	streams     map[string]*stream
+}
+
+func newCapture() *capture {
+	return &capture{byKey: map[string]*connection{}, streams: map[string]*stream{}}
+}
+
+// packetReader is implemented by the pcap and pcapng readers
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// isCapture reports whether the data starts like a pcap or pcapng file
+func isCapture(header []byte) bool {
+	if bytes.HasPrefix(header, pcapngMagic) {
+		return true
+	}
+	for _, magic := range pcapMagics {
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCapture extracts the tcp streams of a pcap or pcapng file
+func readCapture(r *bufio.Reader) (*capture, error) {
+	header, err := r.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	var packets packetReader
+	if bytes.Equal(header, pcapngMagic) {
+		packets, err = pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+	} else {
+		packets, err = pcapgo.NewReader(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newCapture()
+	for {
+		data, _, err := packets.ReadPacketData()
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return c, err
+		}
+		packet := gopacket.NewPacket(data, packets.LinkType(), gopacket.NoCopy)
+		network := packet.NetworkLayer()
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if network == nil || !ok {
+			continue
+		}
+		src := fmt.Sprintf("%s:%d", network.NetworkFlow().Src(), tcp.SrcPort)
+		dst := fmt.Sprintf("%s:%d", network.NetworkFlow().Dst(), tcp.DstPort)
+		seq := tcp.Seq
+		if tcp.SYN {
+			seq++
+		}
+		c.stream(src, dst).add(seq, tcp.Payload)
+	}
+}
+
+// stream returns the stream from src to dst, creating it if needed
+func (c *capture) stream(src, dst string) *stream {
+	key := src + ">" + dst
+	if s, ok := c.streams[key]; ok {
+		return s
+	}
+	s := &stream{src: src, dst: dst, pending: map[uint32][]byte{}}
+	c.streams[key] = s
+
+	endpoints := []string{src, dst}
+	sort.Strings(endpoints)
+	connKey := endpoints[0] + "-" + endpoints[1]
+	conn, ok := c.byKey[connKey]
+	if !ok {
+		conn = &connection{}
+		c.byKey[connKey] = conn
+		c.connections = append(c.connections, conn)
+	}
+	conn.streams = append(conn.streams, s)
+	return s
+}
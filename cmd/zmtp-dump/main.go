@@ -0,0 +1,115 @@
+// Command zmtp-dump prints the ZMTP traffic of capture files.
+//
+// Usage:
+//
+//	zmtp-dump [-max-frame-size bytes] file...
+//
+// Files are pcap or pcapng captures, in which every tcp connection is
+// decoded, or raw captures of the data sent in one direction of a tcp
+// connection, like the files written by tcpflow. Greetings, commands and
+// messages of ZMTP 1.0, 2.0 and 3.x are printed per connection.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bonnefoa/go-zeromq/zmtp"
+)
+
+func main() {
+	maxFrameSize := flag.Int64("max-frame-size", zmtp.DefaultMaxFrameSize, "maximum size of decoded frames")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zmtp-dump [-max-frame-size bytes] file...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	failed := false
+	for _, name := range flag.Args() {
+		err := dumpFile(os.Stdout, name, *maxFrameSize)
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// dumpFile prints the ZMTP traffic of a capture file
+func dumpFile(w io.Writer, name string, maxFrameSize int64) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	header, _ := r.Peek(4)
+	if !isCapture(header) {
+		fmt.Fprintf(w, "stream %s\n", name)
+		dumpStream(w, name, r, maxFrameSize)
+		return nil
+	}
+
+	c, err := readCapture(r)
+	if err != nil && c == nil {
+		return err
+	}
+	for _, conn := range c.connections {
+		first := conn.streams[0]
+		fmt.Fprintf(w, "connection %s <-> %s\n", first.src, first.dst)
+		for _, s := range conn.streams {
+			dumpStream(w, s.src+" > "+s.dst, &s.data, maxFrameSize)
+		}
+	}
+	return err
+}
+
+// dumpStream prints the data sent in one direction of a connection.
+// Decoding errors are printed as the stream can be truncated.
+func dumpStream(w io.Writer, label string, r io.Reader, maxFrameSize int64) {
+	d := zmtp.NewDecoder(r)
+	d.MaxFrameSize = maxFrameSize
+	g, err := d.ReadGreeting()
+	if err == io.EOF {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(w, "%s error %s\n", label, err)
+		return
+	}
+	greeting := []string{g.String()}
+	if d.SocketType != "" {
+		greeting = append(greeting, "socket-type="+d.SocketType)
+	}
+	if len(d.Identity) > 0 {
+		greeting = append(greeting, "identity="+zmtp.FormatFrame(d.Identity))
+	}
+	fmt.Fprintf(w, "%s greeting %s\n", label, strings.Join(greeting, " "))
+
+	for {
+		frames, cmd, err := d.ReadMessage()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s error %s\n", label, err)
+			return
+		}
+		if cmd != nil {
+			fmt.Fprintf(w, "%s command %s\n", label, cmd)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", label, zmtp.FormatMessage(d.SocketType, frames))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bonnefoa/go-zeromq/zmtp"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// handshake builds the data sent by a ZMTP 3.1 socket of the given type
+func handshake(socketType string, messages ...[][]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(zmtp.Greeting{Major: 3, Minor: 1, Mechanism: zmtp.MechanismNull}.Marshal())
+	metadata := zmtp.Metadata{zmtp.PropertySocketType: []byte(socketType)}
+	zmtp.WriteFrame(&buf, zmtp.Command{Name: zmtp.CommandReady, Data: metadata.Marshal()}.Frame())
+	for _, frames := range messages {
+		for i, frame := range frames {
+			zmtp.WriteFrame(&buf, zmtp.Frame{More: i < len(frames)-1, Body: frame})
+		}
+	}
+	return buf.Bytes()
+}
+
+type segment struct {
+	fromClient bool
+	seq        uint32
+	payload    []byte
+}
+
+func writeCapture(t *testing.T, segments []segment) string {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := pcapgo.NewWriter(f)
+	w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	client, server := net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)
+	for _, s := range segments {
+		ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: client, DstIP: server}
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: 5555, Seq: s.seq, ACK: true, PSH: true, Window: 1024}
+		if !s.fromClient {
+			ip.SrcIP, ip.DstIP = server, client
+			tcp.SrcPort, tcp.DstPort = 5555, 40000
+		}
+		tcp.SetNetworkLayerForChecksum(ip)
+		eth := &layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+			DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(s.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(buf.Bytes()), Length: len(buf.Bytes())}
+		w.WritePacket(info, buf.Bytes())
+	}
+	return path
+}
+
+func TestDumpCapture(t *testing.T) {
+	request := handshake("REQ", [][]byte{{}, []byte("hello")})
+	reply := handshake("REP")
+	half := 70
+	path := writeCapture(t, []segment{
+		{fromClient: true, seq: 1000, payload: request[:half]},
+		{fromClient: false, seq: 5000, payload: reply},
+		// Out of order then retransmitted segments
+		{fromClient: true, seq: 1000 + uint32(half) + 5, payload: request[half+5:]},
+		{fromClient: true, seq: 1000 + uint32(half), payload: request[half : half+5]},
+		{fromClient: true, seq: 1000, payload: request[:half]},
+	})
+
+	var out bytes.Buffer
+	err := dumpFile(&out, path, 0)
+	if err != nil {
+		t.Fatal("Error on dump", err)
+	}
+	expected := []string{
+		"connection 10.0.0.1:40000 <-> 10.0.0.2:5555",
+		"10.0.0.1:40000 > 10.0.0.2:5555 greeting ZMTP 3.1 NULL client",
+		`10.0.0.1:40000 > 10.0.0.2:5555 command READY Socket-Type="REQ"`,
+		`10.0.0.1:40000 > 10.0.0.2:5555 message [2] "" | "hello"`,
+		"10.0.0.2:5555 > 10.0.0.1:40000 greeting ZMTP 3.1 NULL client",
+		`10.0.0.2:5555 > 10.0.0.1:40000 command READY Socket-Type="REP"`,
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Unexpected dump:\n%s", out.String())
+	}
+}
+
+func TestDumpStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.raw")
+	data := handshake("SUB", [][]byte{{1, 'a'}})
+	// Truncated message at the end of the stream
+	data = append(data, 0x00, 0x10, 'x')
+	err := os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = dumpFile(&out, path, 0)
+	if err != nil {
+		t.Fatal("Error on dump", err)
+	}
+	expected := []string{
+		"stream " + path,
+		path + " greeting ZMTP 3.1 NULL client",
+		path + ` command READY Socket-Type="SUB"`,
+		path + ` SUBSCRIBE "a"`,
+		path + " error unexpected EOF",
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Unexpected dump:\n%s", out.String())
+	}
+}
+
+func TestDumpCorruptStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupt.raw")
+	// Read as an oversized ZMTP 1.0 identity frame
+	err := os.WriteFile(path, []byte{0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0}, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = dumpFile(&out, path, zmtp.DefaultMaxFrameSize)
+	if err != nil {
+		t.Fatal("Error on dump", err)
+	}
+	expected := path + " error " + zmtp.ErrFrameTooBig.Error()
+	if !strings.Contains(out.String(), expected) {
+		t.Fatalf("Expected %q, got:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,199 @@
+// Command zmtp-proxy is a transparent tcp proxy which logs the ZMTP traffic
+// between zmq sockets.
+//
+// Usage:
+//
+//	zmtp-proxy -listen 127.0.0.1:5556 -target tcp://127.0.0.1:5555 [-delay 100ms] [-drop 0.1]
+//
+// Sockets connect to the listen address instead of the target endpoint.
+// Greetings, commands and messages are logged for both directions of every
+// connection. Messages can be delayed, or dropped with a given probability,
+// to reproduce network issues. Greetings and commands are always forwarded
+// so the connections stay valid.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bonnefoa/go-zeromq/zmtp"
+)
+
+// proxy forwards the connections accepted on a listener to the target
+type proxy struct {
+	target string
+	delay  time.Duration
+	drop   float64
+	logger *log.Logger
+
+	mu     sync.Mutex
+	random *rand.Rand
+	conns  int
+}
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:5556", "address accepting the connections of the sockets")
+	target := flag.String("target", "", "tcp endpoint of the proxied socket, like tcp://127.0.0.1:5555")
+	delay := flag.Duration("delay", 0, "delay added to every message")
+	drop := flag.Float64("drop", 0, "probability to drop a message, between 0 and 1")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the random drops")
+	flag.Parse()
+	if *target == "" || *drop < 0 || *drop > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := newProxy(*target, log.New(os.Stdout, "", log.Ltime|log.Lmicroseconds))
+	p.delay = *delay
+	p.drop = *drop
+	p.random = rand.New(rand.NewSource(*seed))
+	p.logger.Printf("forwarding %s to %s", l.Addr(), p.target)
+	log.Fatal(p.serve(l))
+}
+
+func newProxy(target string, logger *log.Logger) *proxy {
+	return &proxy{
+		target: strings.TrimPrefix(target, "tcp://"),
+		logger: logger,
+		random: rand.New(rand.NewSource(1)),
+	}
+}
+
+// serve accepts connections until the listener is closed
+func (p *proxy) serve(l net.Listener) error {
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.conns++
+		id := p.conns
+		p.mu.Unlock()
+		go p.handle(id, client)
+	}
+}
+
+// handle forwards a connection in both directions until one side closes
+func (p *proxy) handle(id int, client net.Conn) {
+	defer client.Close()
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		p.logger.Printf("#%d connection to %s failed: %s", id, p.target, err)
+		return
+	}
+	defer server.Close()
+	p.logger.Printf("#%d %s connected to %s", id, client.RemoteAddr(), server.RemoteAddr())
+
+	done := make(chan struct{}, 2)
+	go func() {
+		p.pump(id, "client", client, server)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.pump(id, "server", server, client)
+		done <- struct{}{}
+	}()
+	<-done
+	p.logger.Printf("#%d closed", id)
+}
+
+// forwarder writes the data read from the connection to its destination
+// while the greetings are exchanged
+type forwarder struct {
+	r          io.Reader
+	w          io.Writer
+	forwarding bool
+	forwarded  int
+}
+
+func (f *forwarder) Read(data []byte) (int, error) {
+	n, err := f.r.Read(data)
+	if f.forwarding && n > 0 {
+		f.w.Write(data[:n])
+		f.forwarded += n
+	}
+	return n, err
+}
+
+// pump decodes the data sent by one side and forwards it to the other.
+// Greetings are forwarded as they are received since ZMTP 3 peers wait
+// for parts of the greeting of their peer, messages are forwarded once
+// decoded so they can be delayed or dropped.
+func (p *proxy) pump(id int, side string, src io.Reader, dst io.Writer) {
+	prefix := fmt.Sprintf("#%d %s", id, side)
+	f := &forwarder{r: src, w: dst, forwarding: true}
+	d := zmtp.NewDecoder(f)
+	g, err := d.ReadGreeting()
+	if err != nil {
+		p.logger.Printf("%s error %s", prefix, err)
+		return
+	}
+	f.forwarding = false
+	// Data read ahead with the greeting was already forwarded
+	skip := f.forwarded - len(d.Raw())
+	greeting := g.String()
+	if d.SocketType != "" {
+		greeting += " socket-type=" + d.SocketType
+	}
+	if len(d.Identity) > 0 {
+		greeting += " identity=" + zmtp.FormatFrame(d.Identity)
+	}
+	p.logger.Printf("%s greeting %s", prefix, greeting)
+
+	for {
+		frames, cmd, err := d.ReadMessage()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			p.logger.Printf("%s error %s", prefix, err)
+			return
+		}
+		raw := d.Raw()
+		sent := 0
+		if skip > 0 {
+			sent = min(skip, len(raw))
+			skip -= sent
+		}
+		if cmd != nil {
+			p.logger.Printf("%s command %s", prefix, cmd)
+		} else {
+			msg := zmtp.FormatMessage(d.SocketType, frames)
+			if sent == 0 && p.dropped() {
+				p.logger.Printf("%s dropped %s", prefix, msg)
+				continue
+			}
+			if p.delay > 0 && sent == 0 {
+				time.Sleep(p.delay)
+			}
+			p.logger.Printf("%s %s", prefix, msg)
+		}
+		_, err = dst.Write(raw[sent:])
+		if err != nil {
+			return
+		}
+	}
+}
+
+// dropped randomly decides to drop a message
+func (p *proxy) dropped() bool {
+	if p.drop == 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.random.Float64() < p.drop
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// syncBuffer collects the logs written by the proxy goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startProxy binds a socket and starts a proxy in front of it
+func startProxy(t *testing.T, ctx *zmq.Context, serverType zmq.SocketType) (*zmq.Socket, *proxy, string, *syncBuffer) {
+	server, err := ctx.NewSocket(serverType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetOptionInt(zmq.Linger, 0)
+	err = server.Bind("tcp://127.0.0.1:*")
+	if err != nil {
+		t.Fatal("Error on bind", err)
+	}
+	target, _ := server.GetOptionString(zmq.LastEndpoint)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	logs := &syncBuffer{}
+	p := newProxy(target, log.New(logs, "", 0))
+	go p.serve(l)
+	return server, p, "tcp://" + l.Addr().String(), logs
+}
+
+func newClient(t *testing.T, ctx *zmq.Context, clientType zmq.SocketType, endpoint string) *zmq.Socket {
+	client, err := ctx.NewSocket(clientType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetOptionInt(zmq.Linger, 0)
+	client.SetOptionString(zmq.Identity, stringPtr("client"))
+	err = client.Connect(endpoint)
+	if err != nil {
+		t.Fatal("Error on connect", err)
+	}
+	return client
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func expectLogs(t *testing.T, logs *syncBuffer, expected ...string) {
+	for _, line := range expected {
+		if !strings.Contains(logs.String(), line) {
+			t.Fatalf("Expected log %q in:\n%s", line, logs)
+		}
+	}
+}
+
+func TestProxyReqRep(t *testing.T) {
+	ctx, _ := zmq.NewContext()
+	defer ctx.Destroy()
+	server, p, endpoint, logs := startProxy(t, ctx, zmq.Rep)
+	defer server.Close()
+	p.delay = 50 * time.Millisecond
+	client := newClient(t, ctx, zmq.Req, endpoint)
+	defer client.Close()
+
+	start := time.Now()
+	err := client.SendMultipart([][]byte{[]byte("hello"), []byte("world")}, 0)
+	if err != nil {
+		t.Fatal("Error on send", err)
+	}
+	msg, err := server.RecvMultipart(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	server.SendMultipart(msg.Data, 0)
+	_, err = client.RecvMultipart(0)
+	if err != nil {
+		t.Fatal("Error on reply receive", err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*p.delay {
+		t.Fatalf("Expected request and reply to be delayed, round trip took %s", elapsed)
+	}
+	expectLogs(t, logs,
+		"#1 client greeting ZMTP 3.",
+		`#1 client command READY Identity="client" Socket-Type="REQ"`,
+		`#1 server command READY Socket-Type="REP"`,
+		`#1 client message [3] "" | "hello" | "world"`,
+		`#1 server message [3] "" | "hello" | "world"`,
+	)
+}
+
+func TestProxyPubSubDrop(t *testing.T) {
+	ctx, _ := zmq.NewContext()
+	defer ctx.Destroy()
+	server, p, endpoint, logs := startProxy(t, ctx, zmq.Pub)
+	defer server.Close()
+	p.drop = 1
+	client := newClient(t, ctx, zmq.Sub, endpoint)
+	defer client.Close()
+	client.Subscribe([]byte("topic"))
+	client.SetOptionInt(zmq.Rcvtimeo, 100)
+
+	// Wait for the subscription to go through the proxy
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), `"topic"`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Subscription not logged:\n%s", logs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.Send([]byte("topic data"), 0)
+	_, err := client.Recv(0)
+	if err == nil {
+		t.Fatal("Expected the message to be dropped")
+	}
+	expectLogs(t, logs, `#1 server dropped message [1] "topic data"`)
+}
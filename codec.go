@@ -0,0 +1,122 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrUnknownContentType is returned when no registered codec
+// handles the content type of a received value
+var ErrUnknownContentType = errors.New("zmq: no codec registered for content type")
+
+// ErrMalformedValue is returned when a received message
+// is not a [content type, payload] message
+var ErrMalformedValue = errors.New("zmq: malformed value message")
+
+// Codec marshals values to message frames and back
+type Codec interface {
+	// ContentType identifies the encoding, like a MIME type
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Built-in codecs
+var (
+	// JSONCodec encodes values with encoding/json
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec encodes values with encoding/gob.
+	// Each frame is a self-contained gob stream.
+	GobCodec Codec = gobCodec{}
+)
+
+// Codecs registered by content type
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(GobCodec)
+}
+
+// RegisterCodec makes a codec available to decode received values
+// by its content type. It replaces a codec with the same content type.
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecByContentType returns the registered codec of the content type
+func CodecByContentType(contentType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[contentType]
+	return codec, ok
+}
+
+// SendValue encodes the value with the codec and sends it as a two frames
+// message: the codec content type and the payload
+func (s *Socket) SendValue(v interface{}, codec Codec, flag SendFlag) error {
+	payload, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.SendMultipart([][]byte{[]byte(codec.ContentType()), payload}, flag)
+}
+
+// RecvValue receives a message sent with SendValue and decodes it into v
+// with the registered codec of its content type, which is returned
+func (s *Socket) RecvValue(v interface{}, flag SendFlag) (Codec, error) {
+	msg, err := s.RecvMultipart(flag)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Close()
+	if len(msg.Data) != 2 {
+		return nil, ErrMalformedValue
+	}
+	codec, ok := CodecByContentType(string(msg.Data[0]))
+	if !ok {
+		return nil, ErrUnknownContentType
+	}
+	return codec, codec.Unmarshal(msg.Data[1], v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ContentType() string {
+	return "application/x-gob"
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,29 @@
+// Package msgpack provides a MessagePack codec for typed messages.
+// Importing the package registers the codec.
+package msgpack
+
+import (
+	zmq "github.com/bonnefoa/go-zeromq"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes values with MessagePack
+var Codec zmq.Codec = codec{}
+
+func init() {
+	zmq.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return "application/msgpack"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
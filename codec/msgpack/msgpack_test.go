@@ -0,0 +1,33 @@
+package msgpack
+
+import (
+	"reflect"
+	"testing"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+type point struct {
+	X, Y int
+	Name string
+}
+
+func TestCodec(t *testing.T) {
+	value := point{X: 1, Y: 2, Name: "origin"}
+	data, err := Codec.Marshal(value)
+	if err != nil {
+		t.Fatal("Error on marshal", err)
+	}
+	var decoded point
+	err = Codec.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal("Error on unmarshal", err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Fatalf("Expected %+v, got %+v", value, decoded)
+	}
+	codec, ok := zmq.CodecByContentType("application/msgpack")
+	if !ok || codec != Codec {
+		t.Fatal("Expected codec to be registered")
+	}
+}
@@ -0,0 +1,58 @@
+// Package protobuf provides a protocol buffers codec for typed messages.
+// Importing the package registers the codec.
+package protobuf
+
+import (
+	"errors"
+	"reflect"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotProtoMessage is returned when the value is not a protocol buffers message
+var ErrNotProtoMessage = errors.New("protobuf: value is not a proto.Message")
+
+// Codec encodes values implementing proto.Message
+var Codec zmq.Codec = codec{}
+
+func init() {
+	zmq.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal decodes into v which must be a proto.Message
+// or a pointer to a proto.Message pointer, which is allocated
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if msg, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, msg)
+	}
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Ptr {
+		return ErrNotProtoMessage
+	}
+	elem := reflect.New(value.Elem().Type().Elem())
+	msg, ok := elem.Interface().(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	err := proto.Unmarshal(data, msg)
+	if err != nil {
+		return err
+	}
+	value.Elem().Set(elem)
+	return nil
+}
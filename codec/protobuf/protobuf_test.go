@@ -0,0 +1,26 @@
+package protobuf
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	data, err := Codec.Marshal(wrapperspb.String("value"))
+	if err != nil {
+		t.Fatal("Error on marshal", err)
+	}
+	var msg *wrapperspb.StringValue
+	err = Codec.Unmarshal(data, &msg)
+	if err != nil {
+		t.Fatal("Error on unmarshal", err)
+	}
+	if msg.GetValue() != "value" {
+		t.Fatalf("Expected value %q, got %q", "value", msg.GetValue())
+	}
+	_, err = Codec.Marshal("value")
+	if err != ErrNotProtoMessage {
+		t.Fatalf("Expected error %q, got %q", ErrNotProtoMessage, err)
+	}
+}
@@ -0,0 +1,53 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSendRecvValue(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pull, endpoint: TcpEndpoint, clientType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		quote := testQuote{Symbol: "ZMQ", Price: 4.2}
+		err := env.client.SendValue(quote, codec, 0)
+		if err != nil {
+			t.Fatal("Error on value send", err)
+		}
+		var received testQuote
+		used, err := env.server.RecvValue(&received, 0)
+		if err != nil {
+			t.Fatal("Error on value receive", err)
+		}
+		if used != codec {
+			t.Fatalf("Expected codec %s, got %s", codec.ContentType(), used.ContentType())
+		}
+		if !reflect.DeepEqual(received, quote) {
+			t.Fatalf("Expected value %+v, got %+v", quote, received)
+		}
+	}
+
+	err := env.client.SendMultipart([][]byte{[]byte("text/unknown"), []byte("data")}, 0)
+	if err != nil {
+		t.Fatal("Error on send", err)
+	}
+	var received testQuote
+	_, err = env.server.RecvValue(&received, 0)
+	if err != ErrUnknownContentType {
+		t.Fatalf("Expected error %q, got %q", ErrUnknownContentType, err)
+	}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		registered, ok := CodecByContentType(codec.ContentType())
+		if !ok || registered != codec {
+			t.Fatalf("Expected codec %s to be registered", codec.ContentType())
+		}
+	}
+	if _, ok := CodecByContentType("text/unknown"); ok {
+		t.Fatal("Expected unknown content type to have no codec")
+	}
+}
@@ -0,0 +1,22 @@
+package zmq
+
+// ContextConfig configures a context created by NewContext
+type ContextConfig func(ctx *Context) error
+
+// WithOption sets an option of the context
+func WithOption(option ContextOption, value int) ContextConfig {
+	return func(ctx *Context) error {
+		return ctx.Set(option, value)
+	}
+}
+
+// configure applies the configurations to a new context
+func (ctx *Context) configure(configs []ContextConfig) error {
+	for _, config := range configs {
+		err := config(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,3 +1,5 @@
+//go:build !purego
+
 package zmq
 
 /*
@@ -8,6 +10,9 @@ package zmq
 import "C"
 
 import (
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"unsafe"
 )
 
@@ -20,18 +25,54 @@ const (
 	IoThreads  ContextOption = C.ZMQ_IO_THREADS
     // MaxSockets allows to get and set the number of sockets for a context
 	MaxSockets ContextOption = C.ZMQ_MAX_SOCKETS
+	// SocketLimit gets the largest number of sockets MaxSockets can be set to
+	SocketLimit ContextOption = C.ZMQ_SOCKET_LIMIT
+	// Ipv6 enables IPv6 on the sockets created afterwards
+	Ipv6 ContextOption = C.ZMQ_IPV6
+	// Blocky set to 0 makes the sockets created afterwards not linger
+	Blocky ContextOption = C.ZMQ_BLOCKY
+	// ThreadPriority sets the priority of the I/O threads, it can not be
+	// read since it shares its value with SocketLimit
+	ThreadPriority ContextOption = C.ZMQ_THREAD_PRIORITY
+	// ThreadSchedPolicy sets the scheduling policy of the I/O threads
+	ThreadSchedPolicy ContextOption = C.ZMQ_THREAD_SCHED_POLICY
+	// ThreadAffinityCpuAdd adds a cpu to the affinity of the I/O threads
+	ThreadAffinityCpuAdd ContextOption = C.ZMQ_THREAD_AFFINITY_CPU_ADD
+	// ThreadAffinityCpuRemove removes a cpu from the affinity of the I/O threads
+	ThreadAffinityCpuRemove ContextOption = C.ZMQ_THREAD_AFFINITY_CPU_REMOVE
+	// ThreadNamePrefix sets the number prefixing the names of the I/O threads
+	ThreadNamePrefix ContextOption = C.ZMQ_THREAD_NAME_PREFIX
+	// MaxMsgsz allows to get and set the largest size of a message
+	MaxMsgsz ContextOption = C.ZMQ_MAX_MSGSZ
 )
 
 // Context identify the zeromq context
 type Context struct {
-	c unsafe.Pointer
+	c   unsafe.Pointer
+	log atomic.Pointer[contextLog]
+	// sockets are the sockets not closed yet, guarded by mu
+	mu      sync.Mutex
+	sockets map[*Socket]struct{}
+	// Shutdown state, shared with the pure Go backend
+	shutdownState termination
 }
 
-// NewContext creates a new thread safe context
-func NewContext() (ctx *Context, err error) {
-	ctx = &Context{}
+// NewContext creates a new thread safe context. The configurations are
+// applied before any socket is created.
+func NewContext(configs ...ContextConfig) (ctx *Context, err error) {
+	ctx = &Context{
+		sockets:       map[*Socket]struct{}{},
+		shutdownState: termination{done: make(chan struct{})},
+	}
 	ctx.c, err = C.zmq_ctx_new()
-	if ctx == nil {
+	if ctx.c == nil {
+		return nil, err
+	}
+	err = ctx.configure(configs)
+	if err != nil {
+		if derr := ctx.Destroy(); derr != nil {
+			return nil, derr
+		}
 		return nil, err
 	}
 	return ctx, nil
@@ -50,14 +91,54 @@ func (ctx *Context) Destroy() error {
 
 // NewSocket Creates a new socket
 func (ctx *Context) NewSocket(socketType SocketType) (*Socket, error) {
+	// The context may be freed once destroyed by Shutdown
+	if ctx.destroyed() {
+		return nil, ErrTerminated
+	}
 	s, err := C.zmq_socket(ctx.c, C.int(socketType))
-	socket := &Socket{s}
+	socket := &Socket{psocket: s, ctx: ctx, socketType: socketType}
 	if s == nil {
 		return nil, err
 	}
+	ctx.mu.Lock()
+	ctx.sockets[socket] = struct{}{}
+	ctx.mu.Unlock()
+	socket.logEvent("socket created", "", nil)
 	return socket, nil
 }
 
+// unregister removes a socket from the context before it is closed.
+// It returns ENOTSOCK when the socket was already closed.
+func (ctx *Context) unregister(s *Socket) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if _, ok := ctx.sockets[s]; !ok {
+		return syscall.ENOTSOCK
+	}
+	delete(ctx.sockets, s)
+	return nil
+}
+
+// openSockets returns the sockets not closed yet
+func (ctx *Context) openSockets() []*Socket {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	sockets := make([]*Socket, 0, len(ctx.sockets))
+	for s := range ctx.sockets {
+		sockets = append(sockets, s)
+	}
+	return sockets
+}
+
+// shutdown makes the blocking calls of the sockets return ErrTerminated
+func (ctx *Context) shutdown() error {
+	rc, err := C.zmq_ctx_shutdown(ctx.c)
+	if rc == -1 {
+		return err
+	}
+	return nil
+}
+
 // Get context option value
 func (ctx *Context) Get(option ContextOption) (int, error) {
 	rc, err := C.zmq_ctx_get(ctx.c, C.int(option))
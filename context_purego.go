@@ -0,0 +1,199 @@
+//go:build purego
+
+package zmq
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// ContextOption identifies gettable and settable options
+// from context
+type ContextOption int
+
+const (
+	// IoThreads allows to get and set the number of threads for a context
+	IoThreads ContextOption = 1
+	// MaxSockets allows to get and set the number of sockets for a context
+	MaxSockets ContextOption = 2
+	// SocketLimit gets the largest number of sockets MaxSockets can be set to
+	SocketLimit ContextOption = 3
+	// Ipv6 enables IPv6 on the sockets created afterwards
+	Ipv6 ContextOption = 42
+	// Blocky set to 0 makes the sockets created afterwards not linger
+	Blocky ContextOption = 70
+	// ThreadPriority sets the priority of the I/O threads, it can not be
+	// read since it shares its value with SocketLimit
+	ThreadPriority ContextOption = 3
+	// ThreadSchedPolicy sets the scheduling policy of the I/O threads
+	ThreadSchedPolicy ContextOption = 4
+	// ThreadAffinityCpuAdd adds a cpu to the affinity of the I/O threads
+	ThreadAffinityCpuAdd ContextOption = 7
+	// ThreadAffinityCpuRemove removes a cpu from the affinity of the I/O threads
+	ThreadAffinityCpuRemove ContextOption = 8
+	// ThreadNamePrefix sets the number prefixing the names of the I/O threads
+	ThreadNamePrefix ContextOption = 9
+	// MaxMsgsz allows to get and set the largest size of a message
+	MaxMsgsz ContextOption = 5
+)
+
+// Context identify the zeromq context
+type Context struct {
+	mu      sync.Mutex
+	options map[ContextOption]int
+	inproc  map[string]*inprocListener
+	sockets map[*Socket]struct{}
+	closed  *sync.Cond
+	// term is closed when the context is destroyed
+	term     chan struct{}
+	termOnce sync.Once
+	log      atomic.Pointer[contextLog]
+	// Shutdown state, shared with the libzmq backend
+	shutdownState termination
+}
+
+// NewContext creates a new thread safe context. The configurations are
+// applied before any socket is created.
+func NewContext(configs ...ContextConfig) (ctx *Context, err error) {
+	ctx = &Context{
+		options: map[ContextOption]int{
+			IoThreads:   1,
+			MaxSockets:  1023,
+			SocketLimit: 65535,
+			Ipv6:        0,
+			Blocky:      1,
+			MaxMsgsz:    math.MaxInt32,
+		},
+		inproc:  map[string]*inprocListener{},
+		sockets: map[*Socket]struct{}{},
+		term:    make(chan struct{}),
+		shutdownState: termination{done: make(chan struct{})},
+	}
+	ctx.closed = sync.NewCond(&ctx.mu)
+	err = ctx.configure(configs)
+	if err != nil {
+		if derr := ctx.Destroy(); derr != nil {
+			return nil, derr
+		}
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// Destroy a context.
+// Don't forget to close all sockets before otherwise this call
+// will hang forever
+func (ctx *Context) Destroy() error {
+	ctx.shutdown()
+	ctx.mu.Lock()
+	for len(ctx.sockets) > 0 {
+		ctx.closed.Wait()
+	}
+	ctx.mu.Unlock()
+	return nil
+}
+
+// NewSocket Creates a new socket
+func (ctx *Context) NewSocket(socketType SocketType) (*Socket, error) {
+	if _, ok := socketTypeNames[socketType]; !ok {
+		return nil, syscall.EINVAL
+	}
+	select {
+	case <-ctx.term:
+		return nil, ErrTerminated
+	default:
+	}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if len(ctx.sockets) >= ctx.options[MaxSockets] {
+		return nil, syscall.EMFILE
+	}
+	socket := &Socket{
+		ctx:        ctx,
+		socketType: socketType,
+		options:    map[int]int64{},
+		strOptions: map[int][]byte{},
+		filter:     map[string]int{},
+		peerSubs:   map[string]int{},
+		incoming:   make(chan inMsg, defaultOptions[int(Rcvhwm)]),
+		changed:    make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	for option, value := range defaultOptions {
+		socket.options[option] = value
+	}
+	if ctx.options[Ipv6] != 0 {
+		socket.options[int(Ipv4only)] = 0
+	}
+	if ctx.options[Blocky] == 0 {
+		socket.options[int(Linger)] = 0
+	}
+	ctx.sockets[socket] = struct{}{}
+	socket.logEvent("socket created", "", nil)
+	return socket, nil
+}
+
+// unregister removes a closed socket from the context
+func (ctx *Context) unregister(s *Socket) {
+	ctx.mu.Lock()
+	delete(ctx.sockets, s)
+	ctx.closed.Broadcast()
+	ctx.mu.Unlock()
+}
+
+// openSockets returns the sockets not closed yet. Closed sockets stay
+// in the context until their connections are done lingering.
+func (ctx *Context) openSockets() []*Socket {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	sockets := make([]*Socket, 0, len(ctx.sockets))
+	for s := range ctx.sockets {
+		select {
+		case <-s.done:
+		default:
+			sockets = append(sockets, s)
+		}
+	}
+	return sockets
+}
+
+// shutdown makes the blocking calls of the sockets return ErrTerminated
+func (ctx *Context) shutdown() error {
+	ctx.termOnce.Do(func() { close(ctx.term) })
+	return nil
+}
+
+// Get context option value
+func (ctx *Context) Get(option ContextOption) (int, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	value, ok := ctx.options[option]
+	if !ok {
+		return -1, syscall.EINVAL
+	}
+	return value, nil
+}
+
+// Set context option to given value
+func (ctx *Context) Set(option ContextOption, value int) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if value < 0 {
+		return syscall.EINVAL
+	}
+	switch option {
+	case ThreadPriority, ThreadSchedPolicy, ThreadAffinityCpuAdd, ThreadAffinityCpuRemove, ThreadNamePrefix:
+		// Connections are served by goroutines, there is no I/O thread
+		return nil
+	}
+	if _, ok := ctx.options[option]; !ok {
+		return syscall.EINVAL
+	}
+	if option == MaxSockets && value > ctx.options[SocketLimit] {
+		return syscall.EINVAL
+	}
+	ctx.options[option] = value
+	return nil
+}
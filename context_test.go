@@ -1,6 +1,7 @@
 package zmq
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -39,3 +40,53 @@ func TestContextSet(t *testing.T) {
 	}
 	ctx.Destroy()
 }
+
+func TestNewContextOptions(t *testing.T) {
+	ctx, err := NewContext(WithOption(Ipv6, 1), WithOption(Blocky, 0), WithOption(MaxMsgsz, 1024))
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	defer ctx.Destroy()
+	for option, expected := range map[ContextOption]int{Ipv6: 1, Blocky: 0, MaxMsgsz: 1024} {
+		value, err := ctx.Get(option)
+		if err != nil || value != expected {
+			t.Fatalf("Expected option %d to be %d, got %d (err was %v)", option, expected, value, err)
+		}
+	}
+	limit, err := ctx.Get(SocketLimit)
+	if err != nil || limit <= 0 {
+		t.Fatalf("Expected a socket limit, got %d (err was %v)", limit, err)
+	}
+	err = ctx.Set(ThreadPriority, 0)
+	if err != nil {
+		t.Fatal("Error on context set of ThreadPriority", err)
+	}
+
+	soc, err := ctx.NewSocket(Pull)
+	if err != nil {
+		t.Fatal("Error on socket creation", err)
+	}
+	defer soc.Close()
+	linger, _ := soc.GetOptionInt(Linger)
+	ipv4only, _ := soc.GetOptionInt(Ipv4only)
+	if linger != 0 || ipv4only != 0 {
+		t.Fatalf("Expected socket without linger and with IPv6, got linger %d, ipv4only %d", linger, ipv4only)
+	}
+}
+
+func TestNewContextInvalidOption(t *testing.T) {
+	_, err := NewContext(WithOption(MaxSockets, -1))
+	if err == nil {
+		t.Fatal("Expected an error on invalid option")
+	}
+}
+
+func TestNewContextConfigError(t *testing.T) {
+	failure := errors.New("config failure")
+	ctx, err := NewContext(func(ctx *Context) error {
+		return failure
+	})
+	if ctx != nil || err != failure {
+		t.Fatalf("Expected error %q, got %v, %v", failure, ctx, err)
+	}
+}
@@ -0,0 +1,175 @@
+package zmq
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrInvalidEndpoint is returned when an endpoint can not be parsed
+var ErrInvalidEndpoint = errors.New("zmq: invalid endpoint")
+
+// Longest path of ipc endpoints, the size of sun_path on Linux
+const maxIpcPath = 107
+
+// Endpoint is a parsed endpoint. Its fields depend on the transport:
+//
+//	tcp://[source;]host:port
+//	ws://host:port/path
+//	ipc://path
+//	inproc://name
+//	pgm://interface;multicast:port and epgm://interface;multicast:port
+type Endpoint struct {
+	Transport string
+	// Source is the local address of tcp connections, or the interface
+	// of pgm endpoints
+	Source string
+	// Host is a host name, an address, an interface or * for all the
+	// interfaces of tcp and ws endpoints, or the multicast group of pgm
+	// endpoints
+	Host string
+	// Port is 0 for an ephemeral port, written * or ! in endpoints
+	Port int
+	// Name is the path of ipc and ws endpoints and the name of inproc
+	// endpoints
+	Name string
+}
+
+// ParseEndpoint parses and validates an endpoint
+func ParseEndpoint(endpoint string) (Endpoint, error) {
+	invalid := func(reason string) (Endpoint, error) {
+		return Endpoint{}, fmt.Errorf("%w %q: %s", ErrInvalidEndpoint, endpoint, reason)
+	}
+	transport, address, ok := strings.Cut(endpoint, "://")
+	if !ok {
+		return invalid("missing transport")
+	}
+	e := Endpoint{Transport: transport}
+	var err error
+	switch transport {
+	case "tcp":
+		if source, destination, ok := strings.Cut(address, ";"); ok {
+			if source == "" {
+				return invalid("empty source address")
+			}
+			e.Source = source
+			address = destination
+		}
+		e.Host, e.Port, err = parseHostPort(address, true)
+	case "ws":
+		hostPort, path, ok := strings.Cut(address, "/")
+		e.Host, e.Port, err = parseHostPort(hostPort, true)
+		if ok {
+			e.Name = "/" + path
+		}
+	case "ipc":
+		if address == "" || len(address) > maxIpcPath {
+			return invalid("ipc path must have 1 to 107 bytes")
+		}
+		e.Name = address
+	case "inproc":
+		if address == "" {
+			return invalid("empty inproc name")
+		}
+		e.Name = address
+	case "pgm", "epgm":
+		source, destination, ok := strings.Cut(address, ";")
+		if !ok || source == "" {
+			return invalid("missing interface")
+		}
+		e.Source = source
+		e.Host, e.Port, err = parseHostPort(destination, false)
+		if err == nil {
+			if ip := net.ParseIP(e.Host); ip == nil || !ip.IsMulticast() {
+				return invalid("not a multicast address")
+			}
+		}
+	default:
+		return invalid("unknown transport")
+	}
+	if err != nil {
+		return invalid(err.Error())
+	}
+	return e, nil
+}
+
+// parseHostPort splits an address in host and port. Wildcard ports
+// are parsed as port 0 when allowed.
+func parseHostPort(address string, wildcard bool) (string, int, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, errors.New("address must be host:port")
+	}
+	if host == "" {
+		return "", 0, errors.New("empty host")
+	}
+	if wildcard && (port == "*" || port == "!") {
+		return host, 0, nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q", port)
+	}
+	return host, n, nil
+}
+
+// String formats the endpoint for Bind and Connect. Ephemeral ports
+// are written *.
+func (e Endpoint) String() string {
+	switch e.Transport {
+	case "ipc", "inproc":
+		return e.Transport + "://" + e.Name
+	case "pgm", "epgm":
+		return e.Transport + "://" + e.Source + ";" + net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	}
+	port := "*"
+	if e.Port != 0 {
+		port = strconv.Itoa(e.Port)
+	}
+	address := net.JoinHostPort(e.Host, port)
+	if e.Source != "" {
+		address = e.Source + ";" + address
+	}
+	if e.Transport == "ws" {
+		address += e.Name
+	}
+	return e.Transport + "://" + address
+}
+
+// BindRandom binds the socket to a port of the interface chosen at
+// random between minPort and maxPort, and returns the bound endpoint.
+// Ports in use are skipped, EADDRINUSE is returned when none is free.
+// The port is chosen by the system when both bounds are 0.
+func (s *Socket) BindRandom(iface string, minPort, maxPort int) (string, error) {
+	if minPort == 0 && maxPort == 0 {
+		return s.bindLast(fmt.Sprintf("tcp://%s:*", iface))
+	}
+	if minPort < 1 || maxPort > 65535 || minPort > maxPort {
+		return "", syscall.EINVAL
+	}
+	count := maxPort - minPort + 1
+	first := rand.Intn(count)
+	for i := 0; i < count; i++ {
+		port := minPort + (first+i)%count
+		endpoint, err := s.bindLast(fmt.Sprintf("tcp://%s:%d", iface, port))
+		if errors.Is(err, syscall.EADDRINUSE) {
+			continue
+		}
+		return endpoint, err
+	}
+	return "", syscall.EADDRINUSE
+}
+
+// bindLast binds the endpoint and returns the endpoint resolved by
+// the socket
+func (s *Socket) bindLast(endpoint string) (string, error) {
+	err := s.Bind(endpoint)
+	if err != nil {
+		return "", err
+	}
+	return s.GetOptionString(LastEndpoint)
+}
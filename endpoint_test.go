@@ -0,0 +1,89 @@
+package zmq
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		expected Endpoint
+		str      string
+	}{
+		{"tcp://127.0.0.1:5555", Endpoint{Transport: "tcp", Host: "127.0.0.1", Port: 5555}, ""},
+		{"tcp://*:*", Endpoint{Transport: "tcp", Host: "*"}, ""},
+		{"tcp://*:!", Endpoint{Transport: "tcp", Host: "*"}, "tcp://*:*"},
+		{"tcp://[::1]:5555", Endpoint{Transport: "tcp", Host: "::1", Port: 5555}, ""},
+		{"tcp://eth0:5555;server:80", Endpoint{Transport: "tcp", Source: "eth0:5555", Host: "server", Port: 80}, ""},
+		{"ws://localhost:8080/zmq", Endpoint{Transport: "ws", Host: "localhost", Port: 8080, Name: "/zmq"}, ""},
+		{"ws://*:8080", Endpoint{Transport: "ws", Host: "*", Port: 8080}, ""},
+		{"ipc:///tmp/feeds/0", Endpoint{Transport: "ipc", Name: "/tmp/feeds/0"}, ""},
+		{"inproc://workers", Endpoint{Transport: "inproc", Name: "workers"}, ""},
+		{"epgm://eth0;239.192.1.1:5555", Endpoint{Transport: "epgm", Source: "eth0", Host: "239.192.1.1", Port: 5555}, ""},
+	}
+	for _, test := range tests {
+		e, err := ParseEndpoint(test.endpoint)
+		if err != nil {
+			t.Fatalf("Error on parse of %s: %v", test.endpoint, err)
+		}
+		if e != test.expected {
+			t.Fatalf("Expected %+v for %s, got %+v", test.expected, test.endpoint, e)
+		}
+		str := test.str
+		if str == "" {
+			str = test.endpoint
+		}
+		if e.String() != str {
+			t.Fatalf("Expected %s, got %s", str, e)
+		}
+	}
+}
+
+func TestParseInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{
+		"127.0.0.1:5555",
+		"udp://127.0.0.1:5555",
+		"tcp://127.0.0.1",
+		"tcp://:5555",
+		"tcp://127.0.0.1:70000",
+		"tcp://;127.0.0.1:5555",
+		"ipc://",
+		"ipc://" + strings.Repeat("a", 108),
+		"inproc://",
+		"pgm://239.192.1.1:5555",
+		"pgm://eth0;10.0.0.1:5555",
+		"pgm://eth0;239.192.1.1:*",
+	} {
+		_, err := ParseEndpoint(endpoint)
+		if !errors.Is(err, ErrInvalidEndpoint) {
+			t.Fatalf("Expected invalid endpoint %s, got %v", endpoint, err)
+		}
+	}
+}
+
+func TestBindRandom(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pull, clientType: Pull}
+	env.setupEnv()
+	defer env.destroyEnv()
+	endpoint, err := env.server.BindRandom("127.0.0.1", 0, 0)
+	if err != nil {
+		t.Fatal("Error on bind to an ephemeral port", err)
+	}
+	defer env.server.Unbind(endpoint)
+	e, err := ParseEndpoint(endpoint)
+	if err != nil || e.Port == 0 {
+		t.Fatalf("Expected a bound port, got %s (err was %v)", endpoint, err)
+	}
+
+	_, err = env.client.BindRandom("127.0.0.1", e.Port, e.Port)
+	if err != syscall.EADDRINUSE {
+		t.Fatalf("Expected EADDRINUSE, got %v", err)
+	}
+	_, err = env.client.BindRandom("127.0.0.1", 10, 5)
+	if err != syscall.EINVAL {
+		t.Fatalf("Expected EINVAL on invalid range, got %v", err)
+	}
+}
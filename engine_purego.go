@@ -0,0 +1,958 @@
+//go:build purego
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bonnefoa/go-zeromq/zmtp"
+)
+
+// Maximum duration of the ZMTP handshake of a new connection
+const handshakeTimeout = 30 * time.Second
+
+// inMsg is a message read from a peer
+type inMsg struct {
+	peer   *peer
+	frames [][]byte
+}
+
+// outMsg is a message or a command queued to a peer
+type outMsg struct {
+	frames  [][]byte
+	command *zmtp.Command
+}
+
+// peer is a pipe to a remote socket. Pipes of connected endpoints are
+// created by Connect and survive reconnections, like libzmq pipes, so
+// messages can be queued before the connection is established.
+type peer struct {
+	socket   *Socket
+	endpoint string
+	// dialed is true for the pipes of connected endpoints
+	dialed bool
+	out    chan outMsg
+
+	// Fields guarded by the socket mutex
+	identity []byte
+	// minor is the ZMTP minor version of the remote socket
+	minor int
+	// subscriptions of the remote socket
+	subs map[string]int
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	linger    time.Duration
+}
+
+// connection is an established ZMTP connection of a peer.
+// Each connection runs a reading and a writing goroutine.
+type connection struct {
+	conn       *zmtp.Conn
+	raw        net.Conn
+	broken     chan struct{}
+	brokenOnce sync.Once
+	// done is closed once the connection is closed
+	done chan struct{}
+}
+
+func (c *connection) fail() {
+	c.brokenOnce.Do(func() { close(c.broken) })
+}
+
+// endpoint is a bound or connected address
+type endpoint struct {
+	address  string
+	listener net.Listener
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func (e *endpoint) close() {
+	e.once.Do(func() {
+		if e.listener != nil {
+			e.listener.Close()
+		}
+		if e.stop != nil {
+			close(e.stop)
+		}
+	})
+}
+
+// splitAddress splits an endpoint into its transport and address
+func splitAddress(address string) (string, string, error) {
+	i := strings.Index(address, "://")
+	if i < 0 || i+3 == len(address) {
+		return "", "", syscall.EINVAL
+	}
+	return address[:i], address[i+3:], nil
+}
+
+// tcpBindAddress converts the wildcards of a tcp endpoint
+func tcpBindAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "*" {
+		host = ""
+	}
+	if port == "*" || port == "!" {
+		port = "0"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// tcpEndpoint formats a listener address like libzmq last endpoints
+func tcpEndpoint(addr net.Addr) string {
+	tcpAddr := addr.(*net.TCPAddr)
+	host := tcpAddr.IP.String()
+	if tcpAddr.IP.IsUnspecified() {
+		host = "0.0.0.0"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
+}
+
+// lingerDuration converts the linger option, negative waits forever.
+// It is called with the socket mutex held.
+func (s *Socket) lingerDuration() time.Duration {
+	linger := s.options[int(Linger)]
+	if linger < 0 {
+		return -1
+	}
+	return time.Duration(linger) * time.Millisecond
+}
+
+// handshakeConfig builds the ZMTP settings of new connections
+func (s *Socket) handshakeConfig() zmtp.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg := zmtp.Config{
+		SocketType:     socketTypeNames[s.socketType],
+		Identity:       s.strOptions[int(Identity)],
+		MaxMessageSize: s.options[int(Maxmsgsize)],
+	}
+	username := s.strOptions[int(PlainUsername)]
+	if s.options[int(PlainServer)] != 0 {
+		cfg.Mechanism = zmtp.MechanismPlain
+		cfg.AsServer = true
+	} else if len(username) > 0 {
+		cfg.Mechanism = zmtp.MechanismPlain
+		cfg.Username = string(username)
+		cfg.Password = string(s.strOptions[int(PlainPassword)])
+	}
+	return cfg
+}
+
+// newPeer creates the pipe of a connection
+func (s *Socket) newPeer(address string, dialed bool) *peer {
+	s.mu.Lock()
+	hwm := s.options[int(Sndhwm)]
+	s.mu.Unlock()
+	if hwm <= 0 {
+		hwm = defaultOptions[int(Sndhwm)]
+	}
+	return &peer{
+		socket:   s,
+		endpoint: address,
+		dialed:   dialed,
+		out:      make(chan outMsg, hwm),
+		subs:     map[string]int{},
+		closing:  make(chan struct{}),
+	}
+}
+
+// startPeer runs the handshake on a new connection of the peer
+// and starts its goroutines
+func (s *Socket) startPeer(p *peer, raw net.Conn) (*connection, error) {
+	raw.SetDeadline(time.Now().Add(handshakeTimeout))
+	conn, err := zmtp.Handshake(raw, s.handshakeConfig())
+	raw.SetDeadline(time.Time{})
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	c := &connection{
+		conn:   conn,
+		raw:    raw,
+		broken: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := s.attach(p, c); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	go p.readLoop(c)
+	go p.writeLoop(c)
+	return c, nil
+}
+
+// attach adds a peer with a new connection to the socket
+func (s *Socket) attach(p *peer, c *connection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return syscall.ENOTSOCK
+	}
+	attached := false
+	for _, q := range s.peers {
+		attached = attached || q == p
+	}
+	identity, _ := c.conn.PeerMetadata.Get(zmtp.PropertyIdentity)
+	switch s.socketType {
+	case Router:
+		if len(identity) == 0 {
+			s.routingID++
+			identity = make([]byte, 5)
+			binary.BigEndian.PutUint32(identity[1:], s.routingID)
+		} else if q := s.peerByIdentity(identity); q != nil && q != p {
+			return syscall.EADDRINUSE
+		}
+	case Pair:
+		if len(s.peers) > 0 && !attached {
+			return syscall.EISCONN
+		}
+	}
+	p.identity = append([]byte{}, identity...)
+	p.minor = int(c.conn.Peer.Minor)
+	p.subs = map[string]int{}
+	if !attached {
+		s.peers = append(s.peers, p)
+	}
+
+	switch s.socketType {
+	case Sub, Xsub:
+		for topic := range s.filter {
+			p.queue(p.subscription(true, topic))
+		}
+	case Xpub:
+		if welcome := s.strOptions[int(XpubWelcomeMsg)]; len(welcome) > 0 {
+			p.queue(outMsg{frames: [][]byte{welcome}})
+		}
+	}
+	s.running.Add(2)
+	s.signal()
+	return nil
+}
+
+// connectionLost is called when the connection of a peer ends.
+// Pipes of connected endpoints are kept to queue messages until the
+// reconnection, unless DelayAttachOnConnect is set.
+func (s *Socket) connectionLost(p *peer) {
+	s.mu.Lock()
+	keep := p.dialed && s.options[int(DelayAttachOnConnect)] == 0
+	found := false
+	for i, q := range s.peers {
+		if q == p {
+			if !keep {
+				s.peers = append(s.peers[:i], s.peers[i+1:]...)
+			}
+			found = true
+			break
+		}
+	}
+	p.subs = map[string]int{}
+	if found {
+		s.signal()
+	}
+	s.mu.Unlock()
+	if !keep {
+		p.close(0)
+	}
+	if found {
+		s.event(EventDisconnected, 0, p.endpoint)
+	}
+}
+
+// peerByIdentity is called with the socket mutex held
+func (s *Socket) peerByIdentity(identity []byte) *peer {
+	for _, p := range s.peers {
+		if bytes.Equal(p.identity, identity) {
+			return p
+		}
+	}
+	return nil
+}
+
+// signal wakes a sender or a poller waiting for peers
+func (s *Socket) signal() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// queue sends a message to the peer without blocking, it is dropped
+// when the peer queue is full
+func (p *peer) queue(m outMsg) bool {
+	select {
+	case p.out <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscription builds a subscription in the format understood by the peer.
+// ZMTP 3.1 peers use commands, older peers use messages.
+func (p *peer) subscription(subscribe bool, topic string) outMsg {
+	if p.minor >= 1 {
+		name := zmtp.CommandCancel
+		if subscribe {
+			name = zmtp.CommandSubscribe
+		}
+		return outMsg{command: &zmtp.Command{Name: name, Data: []byte(topic)}}
+	}
+	event := SubscriptionEvent{Subscribe: subscribe, Topic: []byte(topic)}
+	return outMsg{frames: [][]byte{event.Bytes()}}
+}
+
+// subscribed reports whether the remote subscriber wants the message.
+// It is called with the socket mutex held.
+func (p *peer) subscribed(topic []byte) bool {
+	for prefix := range p.subs {
+		if bytes.HasPrefix(topic, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// close stops the peer, queued messages are sent during linger
+func (p *peer) close(linger time.Duration) {
+	p.closeOnce.Do(func() {
+		p.linger = linger
+		close(p.closing)
+	})
+}
+
+func (p *peer) write(c *connection, m outMsg) error {
+	if m.command != nil {
+		return c.conn.WriteCommand(*m.command)
+	}
+	return c.conn.WriteMessage(m.frames)
+}
+
+func (p *peer) writeLoop(c *connection) {
+	s := p.socket
+	defer s.running.Done()
+	defer close(c.done)
+	defer c.raw.Close()
+	for {
+		select {
+		case m := <-p.out:
+			if err := p.write(c, m); err != nil {
+				c.fail()
+				return
+			}
+			s.signal()
+		case <-c.broken:
+			return
+		case <-p.closing:
+			p.drain(c)
+			return
+		}
+	}
+}
+
+// drain sends the queued messages until the linger period expires
+func (p *peer) drain(c *connection) {
+	if p.linger == 0 {
+		return
+	}
+	var deadline time.Time
+	if p.linger > 0 {
+		deadline = time.Now().Add(p.linger)
+		c.raw.SetWriteDeadline(deadline)
+	}
+	for {
+		select {
+		case m := <-p.out:
+			if p.write(c, m) != nil {
+				return
+			}
+			if p.linger > 0 && time.Now().After(deadline) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *peer) readLoop(c *connection) {
+	s := p.socket
+	defer s.running.Done()
+	defer s.connectionLost(p)
+	defer c.fail()
+	for {
+		frames, cmd, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if cmd != nil {
+			switch cmd.Name {
+			case zmtp.CommandPing:
+				// The ping context follows its 2 bytes TTL
+				var pingContext []byte
+				if len(cmd.Data) > 2 {
+					pingContext = cmd.Data[2:]
+				}
+				p.queue(outMsg{command: &zmtp.Command{Name: zmtp.CommandPong, Data: pingContext}})
+			case zmtp.CommandSubscribe, zmtp.CommandCancel:
+				s.peerSubscription(p, cmd.Name == zmtp.CommandSubscribe, cmd.Data)
+			case zmtp.CommandError:
+				return
+			}
+			continue
+		}
+		if s.socketType == Pub || s.socketType == Xpub {
+			if len(frames) == 1 {
+				if event, err := ParseSubscriptionEvent(frames[0]); err == nil {
+					s.peerSubscription(p, event.Subscribe, event.Topic)
+				}
+			}
+			continue
+		}
+		select {
+		case s.incoming <- inMsg{peer: p, frames: frames}:
+		case <-p.closing:
+			return
+		}
+	}
+}
+
+// peerSubscription applies a subscription received from a peer.
+// Xpub sockets pass it to the application.
+func (s *Socket) peerSubscription(p *peer, subscribe bool, topic []byte) {
+	key := string(topic)
+	s.mu.Lock()
+	manual := s.options[int(XpubManual)] != 0
+	verbose := s.options[int(XpubVerbose)] != 0
+	verboser := s.options[int(XpubVerboser)] != 0
+	if !manual {
+		updateCount(p.subs, key, subscribe)
+	}
+	notify := false
+	if s.socketType == Xpub {
+		count := s.peerSubs[key]
+		updateCount(s.peerSubs, key, subscribe)
+		if subscribe {
+			notify = count == 0 || verbose || verboser || manual
+		} else {
+			notify = count == 1 || verboser || manual
+		}
+	}
+	s.mu.Unlock()
+	if !notify {
+		return
+	}
+	event := SubscriptionEvent{Subscribe: subscribe, Topic: topic}
+	select {
+	case s.incoming <- inMsg{peer: p, frames: [][]byte{event.Bytes()}}:
+	case <-p.closing:
+	}
+}
+
+// updateCount increments or decrements a subscription count
+func updateCount(counts map[string]int, key string, subscribe bool) {
+	if subscribe {
+		counts[key]++
+		return
+	}
+	if counts[key] <= 1 {
+		delete(counts, key)
+	} else {
+		counts[key]--
+	}
+}
+
+// subscribe handles the Subscribe and Unsubscribe options
+func (s *Socket) subscribe(subscribe bool, topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.socketType {
+	case Sub:
+		count := s.filter[topic]
+		updateCount(s.filter, topic, subscribe)
+		if subscribe && count == 0 || !subscribe && count == 1 {
+			for _, p := range s.peers {
+				p.queue(p.subscription(subscribe, topic))
+			}
+		}
+	case Xpub:
+		if s.options[int(XpubManual)] == 0 {
+			return syscall.EINVAL
+		}
+		if s.lastSubPeer != nil {
+			updateCount(s.lastSubPeer.subs, topic, subscribe)
+		}
+	default:
+		return syscall.EINVAL
+	}
+	return nil
+}
+
+// checkSend validates the socket state before sending a new message
+func (s *Socket) checkSend() error {
+	switch s.socketType {
+	case Sub, Pull:
+		return syscall.ENOTSUP
+	case Req:
+		if s.reqWaiting {
+			return ErrFSM
+		}
+	case Rep:
+		if !s.repWaiting {
+			return ErrFSM
+		}
+	}
+	return nil
+}
+
+// checkRecv validates the socket state before receiving a new message
+func (s *Socket) checkRecv() error {
+	switch s.socketType {
+	case Pub, Push:
+		return syscall.ENOTSUP
+	case Req:
+		if !s.reqWaiting {
+			return ErrFSM
+		}
+	case Rep:
+		if s.repWaiting {
+			return ErrFSM
+		}
+	}
+	return nil
+}
+
+// route sends a complete message to the peers according to the socket type
+func (s *Socket) route(frames [][]byte, dontWait bool) error {
+	switch s.socketType {
+	case Pub, Xpub:
+		s.mu.Lock()
+		for _, p := range s.peers {
+			if p.subscribed(frames[0]) {
+				p.queue(outMsg{frames: frames})
+			}
+		}
+		s.mu.Unlock()
+		return nil
+	case Xsub:
+		if len(frames) == 1 {
+			if event, err := ParseSubscriptionEvent(frames[0]); err == nil {
+				return s.xsubSubscription(event)
+			}
+		}
+		s.mu.Lock()
+		for _, p := range s.peers {
+			p.queue(outMsg{frames: frames})
+		}
+		s.mu.Unlock()
+		return nil
+	case Router:
+		s.mu.Lock()
+		p := s.peerByIdentity(frames[0])
+		mandatory := s.options[int(RouterMandatory)] != 0
+		s.mu.Unlock()
+		if p == nil {
+			if mandatory {
+				return syscall.EHOSTUNREACH
+			}
+			return nil
+		}
+		if !p.queue(outMsg{frames: frames[1:]}) && mandatory {
+			return syscall.EAGAIN
+		}
+		return nil
+	case Rep:
+		reply := make([][]byte, 0, len(s.repEnvelope)+len(frames))
+		reply = append(append(reply, s.repEnvelope...), frames...)
+		p := s.repPeer
+		s.repPeer, s.repEnvelope, s.repWaiting = nil, nil, false
+		if p != nil {
+			p.queue(outMsg{frames: reply})
+		}
+		return nil
+	case Req:
+		frames = append([][]byte{{}}, frames...)
+	}
+
+	p, err := s.roundRobin(outMsg{frames: frames}, dontWait)
+	if err != nil {
+		return err
+	}
+	if s.socketType == Req {
+		s.reqPeer, s.reqWaiting = p, true
+		s.recvBuf = nil
+	}
+	return nil
+}
+
+// xsubSubscription forwards a subscription sent on a xsub socket
+func (s *Socket) xsubSubscription(event SubscriptionEvent) error {
+	topic := string(event.Topic)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updateCount(s.filter, topic, event.Subscribe)
+	for _, p := range s.peers {
+		p.queue(p.subscription(event.Subscribe, topic))
+	}
+	return nil
+}
+
+// roundRobin queues the message to the next peer with room in its queue,
+// waiting for one unless dontWait is set
+func (s *Socket) roundRobin(m outMsg, dontWait bool) (*peer, error) {
+	timeout := s.option(int(Sndtimeo))
+	if timeout == 0 {
+		dontWait = true
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		s.mu.Lock()
+		peers := append([]*peer{}, s.peers...)
+		start := s.next
+		s.mu.Unlock()
+		for i := range peers {
+			p := peers[(start+i)%len(peers)]
+			if p.queue(m) {
+				s.mu.Lock()
+				s.next = start + i + 1
+				s.mu.Unlock()
+				return p, nil
+			}
+		}
+		if dontWait {
+			return nil, syscall.EAGAIN
+		}
+		select {
+		case <-s.changed:
+		case <-expired:
+			return nil, syscall.EAGAIN
+		case <-s.ctx.term:
+			return nil, ErrTerminated
+		case <-s.done:
+			return nil, syscall.ENOTSOCK
+		}
+	}
+}
+
+// fill receives the next message accepted by the socket into the
+// receive buffer
+func (s *Socket) fill(block bool, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if block && timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for len(s.recvBuf) == 0 {
+		if !block {
+			select {
+			case m := <-s.incoming:
+				s.accept(m)
+				continue
+			default:
+				return syscall.EAGAIN
+			}
+		}
+		select {
+		case m := <-s.incoming:
+			s.accept(m)
+		case <-expired:
+			return syscall.EAGAIN
+		case <-s.ctx.term:
+			return ErrTerminated
+		}
+	}
+	return nil
+}
+
+// accept applies the socket type rules to a received message and stores
+// it in the receive buffer. Messages which do not match are dropped.
+func (s *Socket) accept(m inMsg) {
+	frames := m.frames
+	switch s.socketType {
+	case Sub:
+		s.mu.Lock()
+		matched := false
+		for prefix := range s.filter {
+			if bytes.HasPrefix(frames[0], []byte(prefix)) {
+				matched = true
+				break
+			}
+		}
+		s.mu.Unlock()
+		if !matched {
+			return
+		}
+	case Router:
+		frames = append([][]byte{m.peer.identity}, frames...)
+	case Rep:
+		delimiter := -1
+		for i, frame := range frames {
+			if len(frame) == 0 {
+				delimiter = i
+				break
+			}
+		}
+		if delimiter < 0 || delimiter == len(frames)-1 {
+			return
+		}
+		s.repEnvelope = frames[:delimiter+1]
+		s.repPeer, s.repWaiting = m.peer, true
+		frames = frames[delimiter+1:]
+	case Req:
+		if m.peer != s.reqPeer || !s.reqWaiting || len(frames) < 2 || len(frames[0]) != 0 {
+			return
+		}
+		s.reqWaiting = false
+		frames = frames[1:]
+	case Xpub:
+		s.lastSubPeer = m.peer
+	}
+	s.recvBuf = frames
+}
+
+// events returns the poll events ready on the socket
+func (s *Socket) events() pollEvent {
+	var events pollEvent
+	if len(s.recvBuf) > 0 {
+		events |= Pollin
+	}
+	writable := false
+	switch s.socketType {
+	case Pub, Xpub, Xsub, Router:
+		writable = true
+	case Rep:
+		writable = s.repWaiting
+	case Req, Push, Dealer, Pair:
+		writable = !(s.socketType == Req && s.reqWaiting) && s.peerReady()
+	}
+	if writable {
+		events |= Pollout
+	}
+	return events
+}
+
+// peerReady reports whether a peer has room in its queue
+func (s *Socket) peerReady() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.peers {
+		if len(p.out) < cap(p.out) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Socket) acceptLoop(e *endpoint) {
+	for {
+		raw, err := e.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.event(EventAccepted, 0, e.address)
+		go func() {
+			s.startPeer(s.newPeer(e.address, false), raw)
+			if pc, ok := raw.(*pipeConn); ok {
+				close(pc.accepted)
+			}
+		}()
+	}
+}
+
+// dialLoop connects the pipe of an endpoint and reconnects when the
+// connection is lost, until the endpoint is disconnected
+func (s *Socket) dialLoop(e *endpoint, transport, addr string, p *peer, c *connection) {
+	defer s.running.Done()
+	for {
+		if c == nil {
+			c = s.dial(e, transport, addr, p)
+		}
+		if c != nil {
+			select {
+			case <-c.done:
+			case <-e.stop:
+				return
+			}
+		}
+		c = nil
+		ivl := s.option(int(ReconnectIvl))
+		if ivl < 0 {
+			return
+		}
+		s.event(EventConnectRetried, int(ivl), e.address)
+		select {
+		case <-time.After(time.Duration(ivl) * time.Millisecond):
+		case <-e.stop:
+			s.lingerDial(transport, addr, p, time.Duration(ivl)*time.Millisecond)
+			return
+		}
+	}
+}
+
+// dialRaw opens a connection to a connected endpoint
+func (s *Socket) dialRaw(transport, addr string) (net.Conn, <-chan struct{}, error) {
+	switch transport {
+	case "tcp":
+		if i := strings.LastIndex(addr, ";"); i >= 0 {
+			addr = addr[i+1:]
+		}
+		raw, err := net.DialTimeout("tcp", addr, handshakeTimeout)
+		return raw, nil, err
+	case "ipc":
+		raw, err := net.Dial("unix", addr)
+		return raw, nil, err
+	}
+	return s.ctx.dialInproc(addr)
+}
+
+func (s *Socket) dial(e *endpoint, transport, addr string, p *peer) *connection {
+	raw, accepted, err := s.dialRaw(transport, addr)
+	if err != nil {
+		s.event(EventConnectDelayed, 0, e.address)
+		return nil
+	}
+	s.event(EventConnected, 0, e.address)
+	c, err := s.startPeer(p, raw)
+	if accepted != nil {
+		<-accepted
+	}
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// lingerDial keeps connecting a pipe which was not connected when its
+// socket was closed, so its queued messages are sent during linger
+// like libzmq does
+func (s *Socket) lingerDial(transport, addr string, p *peer, ivl time.Duration) {
+	<-p.closing
+	if p.linger == 0 || len(p.out) == 0 {
+		return
+	}
+	var deadline time.Time
+	if p.linger > 0 {
+		deadline = time.Now().Add(p.linger)
+	}
+	for deadline.IsZero() || time.Now().Before(deadline) {
+		raw, accepted, err := s.dialRaw(transport, addr)
+		if err == nil {
+			raw.SetDeadline(time.Now().Add(handshakeTimeout))
+			conn, err := zmtp.Handshake(raw, s.handshakeConfig())
+			raw.SetDeadline(time.Time{})
+			if accepted != nil {
+				<-accepted
+			}
+			if err == nil {
+				p.drain(&connection{conn: conn, raw: raw})
+				raw.Close()
+				return
+			}
+			raw.Close()
+		}
+		time.Sleep(ivl)
+	}
+}
+
+// removeEndpoint unbinds or disconnects an endpoint and closes its peers
+func (s *Socket) removeEndpoint(address string, bound bool) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	e, ok := s.endpoints[address]
+	if !ok || (e.listener != nil) != bound {
+		s.mu.Unlock()
+		return syscall.ENOENT
+	}
+	delete(s.endpoints, address)
+	var closed []*peer
+	peers := s.peers[:0]
+	for _, p := range s.peers {
+		if p.endpoint == address {
+			closed = append(closed, p)
+		} else {
+			peers = append(peers, p)
+		}
+	}
+	s.peers = peers
+	linger := s.lingerDuration()
+	s.mu.Unlock()
+
+	e.close()
+	for _, p := range closed {
+		p.close(linger)
+	}
+	if bound {
+		s.event(EventClosed, 0, address)
+	}
+	return nil
+}
+
+// monitor publishes the events of a socket on an inproc pair socket
+type monitor struct {
+	mu     sync.Mutex
+	socket *Socket
+	events SocketEvent
+}
+
+func newMonitor(ctx *Context, endpoint string, events SocketEvent) (*monitor, error) {
+	soc, err := ctx.NewSocket(Pair)
+	if err != nil {
+		return nil, err
+	}
+	if err := soc.Bind(endpoint); err != nil {
+		soc.Close()
+		return nil, err
+	}
+	return &monitor{socket: soc, events: events}, nil
+}
+
+// event sends an event as a 6 bytes header, with the event and its value,
+// followed by the endpoint, like libzmq 4 does
+func (m *monitor) event(event SocketEvent, value int, address string) {
+	if m == nil || m.events&event == 0 {
+		return
+	}
+	header := make([]byte, 6)
+	binary.LittleEndian.PutUint16(header, uint16(event))
+	binary.LittleEndian.PutUint32(header[2:], uint32(value))
+	m.mu.Lock()
+	m.socket.SendMultipart([][]byte{header, []byte(address)}, DontWait)
+	m.mu.Unlock()
+}
+
+func (m *monitor) close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.socket.Close()
+	m.mu.Unlock()
+}
+
+// event reports an event to the monitor of the socket
+func (s *Socket) event(event SocketEvent, value int, address string) {
+	s.mu.Lock()
+	m := s.monitor
+	s.mu.Unlock()
+	m.event(event, value, address)
+}
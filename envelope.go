@@ -0,0 +1,88 @@
+package zmq
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// HostUnreachableError is returned when a router socket with RouterMandatory
+// cannot route a message to the identity. It wraps syscall.EHOSTUNREACH.
+type HostUnreachableError struct {
+	Identity []byte
+}
+
+func (e *HostUnreachableError) Error() string {
+	return fmt.Sprintf("zmq: host unreachable, no peer with identity %q", e.Identity)
+}
+
+// Unwrap returns the underlying syscall error
+func (e *HostUnreachableError) Unwrap() error {
+	return syscall.EHOSTUNREACH
+}
+
+// Envelope is the routing part of a message received on a router socket:
+// the identities of the hops the message went through, the closest first.
+// On the wire, the envelope is followed by an empty delimiter frame.
+type Envelope [][]byte
+
+// SplitEnvelope splits a message at the first empty delimiter frame into
+// its envelope and its body. A message without delimiter has no envelope.
+func SplitEnvelope(data [][]byte) (Envelope, [][]byte) {
+	for i, frame := range data {
+		if len(frame) == 0 {
+			return Envelope(data[:i]), data[i+1:]
+		}
+	}
+	return nil, data
+}
+
+// Wrap builds the message made of the envelope, the delimiter and the body
+func (e Envelope) Wrap(body [][]byte) [][]byte {
+	data := make([][]byte, 0, len(e)+1+len(body))
+	data = append(data, e...)
+	data = append(data, []byte{})
+	return append(data, body...)
+}
+
+// Unwrap returns the closest hop identity and the envelope of the remaining hops
+func (e Envelope) Unwrap() ([]byte, Envelope) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	return e[0], e[1:]
+}
+
+// SendTo sends the parts to the peer with the given identity,
+// separated by an empty delimiter, on a router socket
+func (s *Socket) SendTo(identity []byte, parts ...[]byte) error {
+	return s.SendEnvelope(Envelope{identity}, parts...)
+}
+
+// SendEnvelope sends the parts with the envelope of a received message,
+// to route a reply through multiple hops
+func (s *Socket) SendEnvelope(envelope Envelope, parts ...[]byte) error {
+	err := s.SendMultipart(envelope.Wrap(parts), 0)
+	if err == syscall.EHOSTUNREACH {
+		identity, _ := envelope.Unwrap()
+		return &HostUnreachableError{Identity: identity}
+	}
+	return err
+}
+
+// RecvFrom receives a message on a router socket and returns its envelope
+// and its body. Data is copied, the returned frames stay valid.
+// The envelope of a router always holds the identity of the peer, even
+// when the peer sent no delimiter, so that replies can be routed.
+func (s *Socket) RecvFrom(flag SendFlag) (Envelope, [][]byte, error) {
+	msg, err := s.RecvMultipart(flag)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := copyMultipart(msg)
+	if s.socketType != Router || len(data) == 0 {
+		envelope, body := SplitEnvelope(data)
+		return envelope, body, nil
+	}
+	envelope, body := SplitEnvelope(data[1:])
+	return append(Envelope{data[0]}, envelope...), body, nil
+}
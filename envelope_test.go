@@ -0,0 +1,106 @@
+package zmq
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestSplitEnvelope(t *testing.T) {
+	data := [][]byte{[]byte("broker"), []byte("client"), []byte{}, []byte("body"), []byte{}}
+	envelope, body := SplitEnvelope(data)
+	expected := Envelope{[]byte("broker"), []byte("client")}
+	if !reflect.DeepEqual(envelope, expected) {
+		t.Fatalf("Expected envelope %q, got %q", expected, envelope)
+	}
+	if !reflect.DeepEqual(body, data[3:]) {
+		t.Fatalf("Expected body %q, got %q", data[3:], body)
+	}
+	if !reflect.DeepEqual(envelope.Wrap(body), data) {
+		t.Fatalf("Expected wrapped message %q, got %q", data, envelope.Wrap(body))
+	}
+	identity, rest := envelope.Unwrap()
+	if string(identity) != "broker" || !reflect.DeepEqual(rest, Envelope{[]byte("client")}) {
+		t.Fatalf("Unexpected unwrap of %q: %q, %q", envelope, identity, rest)
+	}
+
+	envelope, body = SplitEnvelope([][]byte{[]byte("body")})
+	if envelope != nil || len(body) != 1 {
+		t.Fatalf("Expected no envelope, got %q and body %q", envelope, body)
+	}
+}
+
+func TestRouterSendToRecvFrom(t *testing.T) {
+	env := &Env{Tester: t, serverType: Router, endpoint: TcpEndpoint, clientType: Req}
+	env.setupEnv()
+	defer env.destroyEnv()
+
+	err := env.client.SendMultipart([][]byte{[]byte("hello")}, 0)
+	if err != nil {
+		t.Fatal("Error on request send", err)
+	}
+	envelope, body, err := env.server.RecvFrom(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	if len(envelope) != 1 || !reflect.DeepEqual(body, [][]byte{[]byte("hello")}) {
+		t.Fatalf("Unexpected envelope %q and body %q", envelope, body)
+	}
+	identity, _ := envelope.Unwrap()
+	err = env.server.SendTo(identity, []byte("world"))
+	if err != nil {
+		t.Fatal("Error on reply send", err)
+	}
+	reply, err := env.client.Recv(0)
+	if err != nil {
+		t.Fatal("Error on reply receive", err)
+	}
+	if string(reply.Data) != "world" {
+		t.Fatalf("Expected reply 'world', got %q", reply.Data)
+	}
+	reply.Close()
+
+	err = env.server.SetOptionInt(RouterMandatory, 1)
+	if err != nil {
+		t.Fatal("Error on router mandatory set", err)
+	}
+	err = env.server.SendTo([]byte("unknown"), []byte("lost"))
+	var unreachable *HostUnreachableError
+	if !errors.As(err, &unreachable) || string(unreachable.Identity) != "unknown" {
+		t.Fatalf("Expected host unreachable error, got %q", err)
+	}
+	if !errors.Is(err, syscall.EHOSTUNREACH) {
+		t.Fatalf("Expected error to wrap EHOSTUNREACH, got %q", err)
+	}
+}
+
+func TestRouterRecvFromWithoutDelimiter(t *testing.T) {
+	env := &Env{Tester: t, serverType: Router, endpoint: TcpEndpoint, clientType: Dealer}
+	env.setupEnv()
+	defer env.destroyEnv()
+
+	err := env.client.SendMultipart([][]byte{[]byte("hello")}, 0)
+	if err != nil {
+		t.Fatal("Error on request send", err)
+	}
+	envelope, body, err := env.server.RecvFrom(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	if len(envelope) != 1 || !reflect.DeepEqual(body, [][]byte{[]byte("hello")}) {
+		t.Fatalf("Expected the peer identity as envelope, got %q and body %q", envelope, body)
+	}
+	err = env.server.SendEnvelope(envelope, []byte("world"))
+	if err != nil {
+		t.Fatal("Error on reply send", err)
+	}
+	reply, err := env.client.RecvMultipart(0)
+	if err != nil {
+		t.Fatal("Error on reply receive", err)
+	}
+	defer reply.Close()
+	if len(reply.Data) != 2 || len(reply.Data[0]) != 0 || string(reply.Data[1]) != "world" {
+		t.Fatalf("Expected delimited reply 'world', got %q", reply.Data)
+	}
+}
@@ -0,0 +1,297 @@
+package zmq
+
+import (
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Freelance protocol commands
+const (
+	flPing = "PING"
+	flPong = "PONG"
+)
+
+// Default settings of the freelance client
+const (
+	DefaultPingInterval = 2 * time.Second
+	DefaultServerTTL    = 6 * time.Second
+	DefaultTimeout      = 3 * time.Second
+	// Delay before pinging again a server whose connection is not ready
+	flRetryInterval = 100 * time.Millisecond
+	// Delay between two checks of the server stop
+	flPollInterval = time.Second
+)
+
+// A server known by the freelance client
+type flServer struct {
+	endpoint string
+	alive    bool
+	pingAt   time.Time
+	expires  time.Time
+}
+
+// FreelanceClient is a brokerless client sending requests to a pool of
+// servers, as in the freelance pattern. A router socket is connected to
+// every server, using their endpoint as identity. Servers are pinged to
+// track their liveness and each request goes to the first alive server.
+// The request is sent again to the next alive server if its server dies.
+// A FreelanceClient must not be used from several goroutines.
+type FreelanceClient struct {
+	// PingInterval is the delay between two pings of a server
+	PingInterval time.Duration
+	// ServerTTL is the time of silence after which a server is dead
+	ServerTTL time.Duration
+	// Timeout is the time to wait for the reply of a request
+	Timeout time.Duration
+
+	socket   *Socket
+	servers  []*flServer
+	actives  []*flServer
+	sequence int
+}
+
+// NewFreelanceClient creates a client without servers
+func NewFreelanceClient(ctx *Context) (*FreelanceClient, error) {
+	soc, err := ctx.NewSocket(Router)
+	if err != nil {
+		return nil, err
+	}
+	// Fail instead of dropping messages to unreachable servers
+	err = soc.SetOptionInt(RouterMandatory, 1)
+	if err != nil {
+		soc.Close()
+		return nil, err
+	}
+	c := &FreelanceClient{
+		PingInterval: DefaultPingInterval,
+		ServerTTL:    DefaultServerTTL,
+		Timeout:      DefaultTimeout,
+		socket:       soc,
+	}
+	return c, nil
+}
+
+// Connect the client to a server. The server identity must be the endpoint.
+func (c *FreelanceClient) Connect(endpoint string) error {
+	err := c.socket.Connect(endpoint)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	server := &flServer{endpoint: endpoint, pingAt: now, expires: now.Add(c.ServerTTL)}
+	c.servers = append(c.servers, server)
+	return nil
+}
+
+// Request sends a multipart request and waits for the reply.
+// ErrNoReply is returned when no server answered before the timeout.
+func (c *FreelanceClient) Request(request [][]byte) ([][]byte, error) {
+	c.sequence++
+	sequence := []byte(strconv.Itoa(c.sequence))
+	deadline := time.Now().Add(c.Timeout)
+	var target *flServer
+	items := PollItems{&PollItem{Socket: c.socket, Events: Pollin}}
+	for time.Now().Before(deadline) {
+		if (target == nil || !target.alive) && len(c.actives) > 0 {
+			target = c.actives[0]
+			data := append([][]byte{[]byte(target.endpoint), sequence}, request...)
+			err := c.socket.SendMultipart(data, 0)
+			if err == syscall.EHOSTUNREACH {
+				c.markDead(target)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		timeout := c.nextPing().Sub(time.Now())
+		if untilDeadline := deadline.Sub(time.Now()); untilDeadline < timeout {
+			timeout = untilDeadline
+		}
+		if timeout < 0 {
+			timeout = 0
+		}
+		_, err := items.Poll(timeout)
+		if err != nil {
+			return nil, err
+		}
+		if items[0].REvents&Pollin != 0 {
+			msg, err := c.socket.RecvMultipart(0)
+			if err != nil {
+				return nil, err
+			}
+			data := copyMultipart(msg)
+			if len(data) < 2 {
+				continue
+			}
+			c.markAlive(string(data[0]))
+			if string(data[1]) == string(sequence) {
+				return data[2:], nil
+			}
+			// Pong or reply to an abandoned request
+		}
+		err = c.ping()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrNoReply
+}
+
+// Earliest date at which a server needs to be pinged
+func (c *FreelanceClient) nextPing() time.Time {
+	next := time.Now().Add(c.PingInterval)
+	for _, server := range c.servers {
+		if server.pingAt.Before(next) {
+			next = server.pingAt
+		}
+	}
+	return next
+}
+
+// Ping servers when needed and expire silent servers
+func (c *FreelanceClient) ping() error {
+	now := time.Now()
+	for _, server := range c.servers {
+		if server.alive && now.After(server.expires) {
+			c.markDead(server)
+		}
+		if now.Before(server.pingAt) {
+			continue
+		}
+		err := c.socket.SendMultipart([][]byte{[]byte(server.endpoint), []byte(flPing)}, 0)
+		if err == syscall.EHOSTUNREACH {
+			// Connection is not established yet
+			server.pingAt = now.Add(flRetryInterval)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		server.pingAt = now.Add(c.PingInterval)
+	}
+	return nil
+}
+
+// Refresh the server expiry and add it to the actives if it was dead
+func (c *FreelanceClient) markAlive(endpoint string) {
+	for _, server := range c.servers {
+		if server.endpoint != endpoint {
+			continue
+		}
+		server.expires = time.Now().Add(c.ServerTTL)
+		if !server.alive {
+			server.alive = true
+			c.actives = append(c.actives, server)
+		}
+		return
+	}
+}
+
+func (c *FreelanceClient) markDead(server *flServer) {
+	server.alive = false
+	for i, active := range c.actives {
+		if active == server {
+			c.actives = append(c.actives[:i], c.actives[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close the client socket, pending requests are discarded
+func (c *FreelanceClient) Close() error {
+	c.socket.SetOptionInt(Linger, 0)
+	return c.socket.Close()
+}
+
+// FreelanceServer answers requests and pings of freelance clients.
+// Its router socket identity is its endpoint.
+type FreelanceServer struct {
+	socket  *Socket
+	handler RequestHandler
+	err     error
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewFreelanceServer creates a server bound to the endpoint.
+// Requests are answered with the given handler.
+func NewFreelanceServer(ctx *Context, endpoint string, handler RequestHandler) (*FreelanceServer, error) {
+	soc, err := ctx.NewSocket(Router)
+	if err != nil {
+		return nil, err
+	}
+	err = soc.SetOptionString(Identity, &endpoint)
+	if err == nil {
+		err = soc.Bind(endpoint)
+	}
+	if err != nil {
+		soc.Close()
+		return nil, err
+	}
+	return &FreelanceServer{socket: soc, handler: handler}, nil
+}
+
+// Start answering requests in a new goroutine
+func (s *FreelanceServer) Start() {
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run()
+}
+
+// Close stops the server and closes its socket.
+// The error which interrupted the server, if any, is returned.
+func (s *FreelanceServer) Close() error {
+	if s.done != nil {
+		close(s.done)
+		<-s.stopped
+	}
+	s.socket.SetOptionInt(Linger, 0)
+	err := s.socket.Close()
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+func (s *FreelanceServer) run() {
+	defer close(s.stopped)
+	items := PollItems{&PollItem{Socket: s.socket, Events: Pollin}}
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		_, err := items.Poll(flPollInterval)
+		if err != nil {
+			s.err = err
+			return
+		}
+		if items[0].REvents&Pollin == 0 {
+			continue
+		}
+		msg, err := s.socket.RecvMultipart(0)
+		if err != nil {
+			s.err = err
+			return
+		}
+		data := copyMultipart(msg)
+		if len(data) < 2 {
+			continue
+		}
+		var reply [][]byte
+		if len(data) == 2 && string(data[1]) == flPing {
+			reply = [][]byte{data[0], []byte(flPong)}
+		} else {
+			reply = append([][]byte{data[0], data[1]}, s.handler(data[2:])...)
+		}
+		err = s.socket.SendMultipart(reply, 0)
+		if err != nil {
+			s.err = err
+			return
+		}
+	}
+}
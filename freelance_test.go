@@ -0,0 +1,85 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestFreelanceServer(t *testing.T, ctx *Context, endpoint string) *FreelanceServer {
+	server, err := NewFreelanceServer(ctx, endpoint, func(request [][]byte) [][]byte {
+		return [][]byte{[]byte(endpoint)}
+	})
+	if err != nil {
+		t.Fatal("Error on freelance server creation", err)
+	}
+	server.Start()
+	return server
+}
+
+func TestFreelanceClient(t *testing.T) {
+	env := &Env{Tester: t}
+	env.setupEnv()
+	defer env.destroyEnv()
+	firstEndpoint := InprocEndpoint + "_freelance_1"
+	secondEndpoint := InprocEndpoint + "_freelance_2"
+
+	first := newTestFreelanceServer(t, env.Context, firstEndpoint)
+	second := newTestFreelanceServer(t, env.Context, secondEndpoint)
+	defer second.Close()
+
+	client, err := NewFreelanceClient(env.Context)
+	if err != nil {
+		t.Fatal("Error on freelance client creation", err)
+	}
+	defer client.Close()
+	client.PingInterval = 50 * time.Millisecond
+	client.ServerTTL = 150 * time.Millisecond
+	client.Timeout = time.Second
+	err = client.Connect(firstEndpoint)
+	if err != nil {
+		t.Fatal("Error on freelance client connect", err)
+	}
+	reply, err := client.Request([][]byte{[]byte("lookup")})
+	if err != nil {
+		t.Fatal("Error on request", err)
+	}
+	expected := [][]byte{[]byte(firstEndpoint)}
+	if !reflect.DeepEqual(reply, expected) {
+		t.Fatalf("Expected reply %q, got %q", expected, reply)
+	}
+
+	// Requests fail over to the remaining server
+	err = client.Connect(secondEndpoint)
+	if err != nil {
+		t.Fatal("Error on freelance client connect", err)
+	}
+	first.Close()
+	time.Sleep(2 * client.ServerTTL)
+	reply, err = client.Request([][]byte{[]byte("lookup")})
+	if err != nil {
+		t.Fatal("Error on request", err)
+	}
+	expected = [][]byte{[]byte(secondEndpoint)}
+	if !reflect.DeepEqual(reply, expected) {
+		t.Fatalf("Expected reply %q, got %q", expected, reply)
+	}
+}
+
+func TestFreelanceClientNoServer(t *testing.T) {
+	env := &Env{Tester: t}
+	env.setupEnv()
+	defer env.destroyEnv()
+
+	client, err := NewFreelanceClient(env.Context)
+	if err != nil {
+		t.Fatal("Error on freelance client creation", err)
+	}
+	defer client.Close()
+	client.Timeout = 100 * time.Millisecond
+	client.Connect(InprocEndpoint + "_freelance_none")
+	_, err = client.Request([][]byte{[]byte("lookup")})
+	if err != ErrNoReply {
+		t.Fatalf("Expected error %q, got %q", ErrNoReply, err)
+	}
+}
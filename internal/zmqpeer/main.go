@@ -0,0 +1,81 @@
+// Command zmqpeer runs a socket of the libzmq backend. Tests of the purego
+// backend use it as a peer to check the interoperability with libzmq.
+//
+// A rep socket echoes the received messages, a sub socket prints the
+// received messages, one per line, and exits after count messages.
+// The line "ready" is printed once the socket is bound or connected.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+var socketTypes = map[string]zmq.SocketType{
+	"rep": zmq.Rep,
+	"sub": zmq.Sub,
+}
+
+func main() {
+	socketType := flag.String("type", "rep", "socket type, rep or sub")
+	bind := flag.String("bind", "", "endpoint to bind")
+	connect := flag.String("connect", "", "endpoint to connect")
+	topic := flag.String("topic", "", "subscription of the sub socket")
+	count := flag.Int("count", 1, "number of messages received by the sub socket")
+	flag.Parse()
+
+	tp, ok := socketTypes[*socketType]
+	if !ok {
+		log.Fatalf("unsupported socket type %q", *socketType)
+	}
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		log.Fatal(err)
+	}
+	soc, err := ctx.NewSocket(tp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	soc.SetOptionInt(zmq.Linger, 0)
+	if tp == zmq.Sub {
+		err = soc.Subscribe([]byte(*topic))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *bind != "" {
+		err = soc.Bind(*bind)
+	} else {
+		err = soc.Connect(*connect)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("ready")
+
+	for i := 0; tp == zmq.Rep || i < *count; i++ {
+		msg, err := soc.RecvMultipart(0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if tp == zmq.Rep {
+			err = soc.SendMultipart(msg.Data, 0)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			frames := make([]string, len(msg.Data))
+			for j, frame := range msg.Data {
+				frames[j] = string(frame)
+			}
+			fmt.Println(strings.Join(frames, " "))
+		}
+		msg.Close()
+	}
+	soc.Close()
+	ctx.Destroy()
+}
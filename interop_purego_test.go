@@ -0,0 +1,97 @@
+//go:build purego
+
+package zmq
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const InteropEndpoint = "tcp://127.0.0.1:9998"
+
+// startLibzmqPeer runs the zmqpeer command built with the libzmq backend.
+// The test is skipped when libzmq is not available.
+func startLibzmqPeer(t *testing.T, args ...string) *bufio.Scanner {
+	path := filepath.Join(t.TempDir(), "zmqpeer")
+	build := exec.Command("go", "build", "-o", path, "./internal/zmqpeer")
+	build.Env = append(os.Environ(), "CGO_ENABLED=1")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("libzmq peer unavailable: %s", out)
+	}
+	cmd := exec.Command(path, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	lines := bufio.NewScanner(stdout)
+	if !lines.Scan() || lines.Text() != "ready" {
+		t.Skip("libzmq peer failed to start")
+	}
+	return lines
+}
+
+func TestInteropReqRep(t *testing.T) {
+	startLibzmqPeer(t, "-type", "rep", "-bind", InteropEndpoint)
+	env := &Env{Tester: t, clientType: Req, endpoint: InteropEndpoint}
+	env.Context, _ = NewContext()
+	env.setupClient()
+	defer env.destroyClient()
+	env.client.SetOptionInt(Linger, 0)
+
+	for _, request := range []string{"hello", "world"} {
+		err := env.client.SendMultipart([][]byte{[]byte(request), []byte("part")}, 0)
+		if err != nil {
+			t.Fatal("Error on send", err)
+		}
+		msg, err := env.client.RecvMultipart(0)
+		if err != nil {
+			t.Fatal("Error on receive", err)
+		}
+		if len(msg.Data) != 2 || string(msg.Data[0]) != request {
+			t.Fatalf("Expected echo of %q, got %q", request, msg.Data)
+		}
+	}
+}
+
+func TestInteropPubSub(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pub, endpoint: InteropEndpoint}
+	env.setupServer()
+	defer env.Destroy()
+	defer env.destroyServer()
+	env.server.SetOptionInt(Linger, 0)
+	lines := startLibzmqPeer(t, "-type", "sub", "-connect", InteropEndpoint, "-topic", "news")
+
+	received := make(chan string, 1)
+	go func() {
+		if lines.Scan() {
+			received <- lines.Text()
+		}
+	}()
+	// Publish until the subscription reached the publisher
+	timeout := time.After(5 * time.Second)
+	for {
+		env.server.Send([]byte("weather"), 0)
+		env.server.Send([]byte("news today"), 0)
+		select {
+		case line := <-received:
+			if line != "news today" {
+				t.Fatalf("Expected filtered message, got %q", line)
+			}
+			return
+		case <-timeout:
+			t.Fatal("Subscriber did not receive any message")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
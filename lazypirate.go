@@ -0,0 +1,103 @@
+package zmq
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoReply is returned by a ReliableReq when the server did not answer
+// after all retries
+var ErrNoReply = errors.New("zmq: no reply from server, abandoning request")
+
+// ReliableReq is a request client implementing the Lazy Pirate pattern.
+// A request socket waiting for a reply which never comes is stuck forever.
+// ReliableReq polls the socket with a timeout and, on expiration, closes
+// and recreates the socket before sending the request again.
+type ReliableReq struct {
+	// Timeout is the time to wait for a reply before retrying
+	Timeout time.Duration
+	// Retries is the number of attempts before giving up
+	Retries int
+
+	ctx      *Context
+	endpoint string
+	socket   *Socket
+}
+
+// NewReliableReq creates a request client connected to the given endpoint
+func NewReliableReq(ctx *Context, endpoint string, timeout time.Duration, retries int) (*ReliableReq, error) {
+	r := &ReliableReq{Timeout: timeout, Retries: retries, ctx: ctx, endpoint: endpoint}
+	err := r.connect()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Create a new request socket and connect it to the endpoint
+func (r *ReliableReq) connect() error {
+	soc, err := r.ctx.NewSocket(Req)
+	if err != nil {
+		return err
+	}
+	err = soc.Connect(r.endpoint)
+	if err != nil {
+		soc.Close()
+		return err
+	}
+	r.socket = soc
+	return nil
+}
+
+// Drop pending messages and close the current socket
+func (r *ReliableReq) disconnect() error {
+	if r.socket == nil {
+		return nil
+	}
+	r.socket.SetOptionInt(Linger, 0)
+	err := r.socket.Close()
+	r.socket = nil
+	return err
+}
+
+// Request sends a multipart request and waits for the reply.
+// The request is sent again on a fresh socket each time the timeout expires.
+// ErrNoReply is returned once all retries are exhausted.
+func (r *ReliableReq) Request(data [][]byte) ([][]byte, error) {
+	for retries := r.Retries; retries > 0; retries-- {
+		if r.socket == nil {
+			err := r.connect()
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := r.socket.SendMultipart(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		items := PollItems{&PollItem{Socket: r.socket, Events: Pollin}}
+		count, err := items.Poll(r.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			msg, err := r.socket.RecvMultipart(0)
+			if err != nil {
+				return nil, err
+			}
+			return copyMultipart(msg), nil
+		}
+		// No reply, the socket is stuck in its state machine
+		// and needs to be recreated
+		err = r.disconnect()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrNoReply
+}
+
+// Close the underlying socket, pending requests are discarded
+func (r *ReliableReq) Close() error {
+	return r.disconnect()
+}
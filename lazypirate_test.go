@@ -0,0 +1,52 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReliableReq(t *testing.T) {
+	env := &Env{Tester: t, serverType: Rep, endpoint: TcpEndpoint}
+	env.setupServer()
+	defer env.destroyEnv()
+
+	go func() {
+		msg, err := env.server.RecvMultipart(0)
+		if err != nil {
+			return
+		}
+		env.server.SendMultipart(msg.Data, 0)
+		msg.Close()
+	}()
+
+	client, err := NewReliableReq(env.Context, TcpEndpoint, time.Second, 3)
+	if err != nil {
+		t.Fatal("Error on reliable request client creation", err)
+	}
+	defer client.Close()
+	data := [][]byte{[]byte("hello"), []byte("world")}
+	reply, err := client.Request(data)
+	if err != nil {
+		t.Fatal("Error on request", err)
+	}
+	if !reflect.DeepEqual(reply, data) {
+		t.Fatalf("Expected reply %q, got %q", data, reply)
+	}
+}
+
+func TestReliableReqNoReply(t *testing.T) {
+	env := &Env{Tester: t}
+	env.setupEnv()
+	defer env.destroyEnv()
+
+	client, err := NewReliableReq(env.Context, TcpEndpoint, 50*time.Millisecond, 2)
+	if err != nil {
+		t.Fatal("Error on reliable request client creation", err)
+	}
+	defer client.Close()
+	_, err = client.Request([][]byte{[]byte("hello")})
+	if err != ErrNoReply {
+		t.Fatalf("Expected error %q, got %q", ErrNoReply, err)
+	}
+}
@@ -0,0 +1,99 @@
+package zmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"syscall"
+)
+
+// Socket type names exchanged during the ZMTP handshake
+var socketTypeNames = map[SocketType]string{
+	Pair:   "PAIR",
+	Pub:    "PUB",
+	Sub:    "SUB",
+	Req:    "REQ",
+	Rep:    "REP",
+	Dealer: "DEALER",
+	Router: "ROUTER",
+	Pull:   "PULL",
+	Push:   "PUSH",
+	Xpub:   "XPUB",
+	Xsub:   "XSUB",
+}
+
+func (t SocketType) String() string {
+	if name, ok := socketTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("SocketType(%d)", int(t))
+}
+
+// contextLog is the logger of a context with the level of its records
+type contextLog struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// SetLogger logs the events of the sockets of the context: creation and
+// close, bind, connect, unbind and disconnect, monitor events received
+// with RecvMonitorEvent and errors of sends and receives. Events are
+// logged at the given level and errors at least at the warning level,
+// except EAGAIN of non blocking calls and timeouts. A nil logger disables
+// logging, which costs a single atomic load per event.
+func (ctx *Context) SetLogger(logger *slog.Logger, level slog.Level) {
+	if logger == nil {
+		ctx.log.Store(nil)
+		return
+	}
+	ctx.log.Store(&contextLog{logger: logger, level: level})
+}
+
+// logger returns the logger of the context and the level of the record
+// when it is enabled
+func (ctx *Context) logger(err error) (*slog.Logger, slog.Level, bool) {
+	l := ctx.log.Load()
+	if l == nil {
+		return nil, 0, false
+	}
+	level := l.level
+	if err != nil && !errors.Is(err, syscall.EAGAIN) && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	if !l.logger.Enabled(context.Background(), level) {
+		return nil, 0, false
+	}
+	return l.logger, level, true
+}
+
+// logEvent logs an event of the socket on the given endpoint. Events
+// of the whole socket are logged with the endpoints of the socket.
+func (s *Socket) logEvent(msg string, endpoint string, err error) {
+	logger, level, ok := s.ctx.logger(err)
+	if !ok {
+		return
+	}
+	attrs := []slog.Attr{slog.String("socket_type", s.socketType.String())}
+	if endpoint != "" {
+		attrs = append(attrs, slog.String("endpoint", endpoint))
+	} else if endpoints := s.endpointList(); len(endpoints) > 0 {
+		attrs = append(attrs, slog.Any("endpoints", endpoints))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// logMonitorEvent logs an event received from a socket monitor
+func (s *Socket) logMonitorEvent(event MonitorEvent) {
+	logger, level, ok := s.ctx.logger(nil)
+	if !ok {
+		return
+	}
+	logger.LogAttrs(context.Background(), level, "monitor event",
+		slog.String("event", event.Event.String()),
+		slog.Int("value", event.Value),
+		slog.String("endpoint", event.Endpoint))
+}
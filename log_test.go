@@ -0,0 +1,133 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// decodeRecords returns the records written by a JSON handler
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		record := map[string]interface{}{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal("Error when decoding log record", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSetLogger(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pull, clientType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+	var buf bytes.Buffer
+	env.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelInfo)
+
+	soc, err := env.NewSocket(Req)
+	if err != nil {
+		t.Fatal("Error when creating socket", err)
+	}
+	err = soc.Connect(TcpEndpoint)
+	if err != nil {
+		t.Fatal("Error on connect", err)
+	}
+	soc.SetOptionInt(Linger, 0)
+	soc.Close()
+	err = env.server.Send([]byte("data"), 0)
+	if err == nil {
+		t.Fatal("Expected an error on send from a pull socket")
+	}
+
+	records := decodeRecords(t, &buf)
+	expected := []struct {
+		msg, level string
+	}{
+		{"socket created", "INFO"},
+		{"socket connected", "INFO"},
+		{"socket closed", "INFO"},
+		{"send failed", "WARN"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %v", len(expected), records)
+	}
+	for i, e := range expected {
+		if records[i]["msg"] != e.msg || records[i]["level"] != e.level {
+			t.Fatalf("Expected %s at %s, got %v", e.msg, e.level, records[i])
+		}
+	}
+	if records[0]["socket_type"] != "REQ" || records[1]["endpoint"] != TcpEndpoint {
+		t.Fatalf("Unexpected attributes %v", records[:2])
+	}
+	endpoints, _ := records[2]["endpoints"].([]interface{})
+	if len(endpoints) != 1 || endpoints[0] != TcpEndpoint {
+		t.Fatalf("Expected endpoints of closed socket, got %v", records[2])
+	}
+	if records[3]["socket_type"] != "PULL" || records[3]["error"] == nil {
+		t.Fatalf("Unexpected attributes %v", records[3])
+	}
+}
+
+func TestSetLoggerLevel(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pull, clientType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+	env.SetLogger(slog.New(handler), slog.LevelDebug)
+
+	env.server.Bind(TcpEndpoint)
+	env.server.Send([]byte("data"), 0)
+	records := decodeRecords(t, &buf)
+	if len(records) != 1 || records[0]["msg"] != "send failed" {
+		t.Fatalf("Expected only the send error, got %v", records)
+	}
+
+	env.SetLogger(nil, slog.LevelInfo)
+	env.server.Unbind(TcpEndpoint)
+	env.server.Send([]byte("data"), 0)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no record without logger, got %s", buf.String())
+	}
+}
+
+func TestMonitorEventLogged(t *testing.T) {
+	env := &Env{Tester: t, serverType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+	monitorEndpoint := "inproc://monitor_logged"
+	env.server.Monitor(monitorEndpoint, EventListening)
+	monitor, err := env.NewSocket(Pair)
+	if err != nil {
+		t.Fatal("Error when creating new monitor pair socket", err)
+	}
+	defer monitor.Close()
+	monitor.Connect(monitorEndpoint)
+	var buf bytes.Buffer
+	env.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelInfo)
+
+	env.server.Bind(TcpEndpoint)
+	defer env.server.Unbind(TcpEndpoint)
+	_, err = monitor.RecvMonitorEvent(0)
+	if err != nil {
+		t.Fatal("Error when receiving monitor event", err)
+	}
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 || records[1]["msg"] != "monitor event" ||
+		records[1]["event"] != "listening" || records[1]["endpoint"] != TcpEndpoint {
+		t.Fatalf("Expected bind and monitor event records, got %v", records)
+	}
+}
+
+func TestSocketTypeString(t *testing.T) {
+	if Router.String() != "ROUTER" {
+		t.Fatalf("Expected ROUTER, got %s", Router)
+	}
+	if SocketType(42).String() != "SocketType(42)" {
+		t.Fatalf("Unexpected name %s", SocketType(42))
+	}
+}
@@ -0,0 +1,213 @@
+package zmq
+
+import (
+	"container/list"
+	"strings"
+	"time"
+)
+
+// Delay between two checks of the proxy stop
+const lvcPollInterval = time.Second
+
+// A cached message with its topic and reception date
+type lvcEntry struct {
+	topic    string
+	data     [][]byte
+	received time.Time
+}
+
+// LVCProxy is a last value cache proxy between publishers and subscribers.
+// It keeps the most recent message of each topic, the topic being the first
+// frame, and replays it as soon as a subscriber subscribes to the topic,
+// so late joiners get the current state without waiting for the next update.
+type LVCProxy struct {
+	// CacheSize is the maximum number of cached topics, the least recently
+	// updated topics are evicted first. 0 means unlimited.
+	CacheSize int
+	// MaxAge is the duration after which a cached message is evicted.
+	// 0 means cached messages never expire.
+	MaxAge time.Duration
+
+	frontend *Socket
+	backend  *Socket
+	cache    map[string]*list.Element
+	lru      *list.List
+	err      error
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewLVCProxy creates a proxy whose xsub frontend is connected to the
+// publisher endpoint and whose xpub backend is bound to the subscriber
+// endpoint. The frontend subscribes to all topics.
+func NewLVCProxy(ctx *Context, publisherEndpoint, subscriberEndpoint string) (*LVCProxy, error) {
+	p := &LVCProxy{
+		cache: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+	var err error
+	p.frontend, err = ctx.NewSocket(Xsub)
+	if err != nil {
+		return nil, err
+	}
+	p.backend, err = ctx.NewSocket(Xpub)
+	if err != nil {
+		p.closeSockets()
+		return nil, err
+	}
+	// Receive every subscription, even already known ones,
+	// to replay the cache to each new subscriber
+	err = p.backend.SetOptionInt(XpubVerbose, 1)
+	if err == nil {
+		err = p.backend.Bind(subscriberEndpoint)
+	}
+	if err == nil {
+		err = p.frontend.Connect(publisherEndpoint)
+	}
+	if err == nil {
+		err = p.frontend.Send(SubscriptionEvent{Subscribe: true}.Bytes(), 0)
+	}
+	if err != nil {
+		p.closeSockets()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Start forwarding messages in a new goroutine
+func (p *LVCProxy) Start() {
+	p.done = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go p.run()
+}
+
+// Close stops the proxy and closes its sockets.
+// The error which interrupted the proxy, if any, is returned.
+func (p *LVCProxy) Close() error {
+	if p.done != nil {
+		close(p.done)
+		<-p.stopped
+	}
+	err := p.closeSockets()
+	if p.err != nil {
+		return p.err
+	}
+	return err
+}
+
+func (p *LVCProxy) closeSockets() error {
+	var err error
+	for _, soc := range []*Socket{p.frontend, p.backend} {
+		if soc == nil {
+			continue
+		}
+		soc.SetOptionInt(Linger, 0)
+		cerr := soc.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Store the message as the last value of its topic
+func (p *LVCProxy) store(data [][]byte) {
+	topic := string(data[0])
+	entry := &lvcEntry{topic: topic, data: data, received: time.Now()}
+	if elem, ok := p.cache[topic]; ok {
+		elem.Value = entry
+		p.lru.MoveToFront(elem)
+	} else {
+		p.cache[topic] = p.lru.PushFront(entry)
+	}
+	if p.CacheSize > 0 && p.lru.Len() > p.CacheSize {
+		p.remove(p.lru.Back())
+	}
+}
+
+func (p *LVCProxy) remove(elem *list.Element) {
+	p.lru.Remove(elem)
+	delete(p.cache, elem.Value.(*lvcEntry).topic)
+}
+
+// Evict the cached messages older than MaxAge
+func (p *LVCProxy) expire() {
+	if p.MaxAge <= 0 {
+		return
+	}
+	limit := time.Now().Add(-p.MaxAge)
+	for elem := p.lru.Back(); elem != nil; elem = p.lru.Back() {
+		if elem.Value.(*lvcEntry).received.After(limit) {
+			return
+		}
+		p.remove(elem)
+	}
+}
+
+// Send the cached messages whose topic matches the subscription prefix
+func (p *LVCProxy) replay(prefix string) error {
+	for elem := p.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*lvcEntry)
+		if !strings.HasPrefix(entry.topic, prefix) {
+			continue
+		}
+		err := p.backend.SendMultipart(entry.data, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *LVCProxy) run() {
+	defer close(p.stopped)
+	items := PollItems{
+		&PollItem{Socket: p.frontend, Events: Pollin},
+		&PollItem{Socket: p.backend, Events: Pollin},
+	}
+	for {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+		_, err := items.Poll(lvcPollInterval)
+		if err != nil {
+			p.err = err
+			return
+		}
+		p.expire()
+
+		if items[0].REvents&Pollin != 0 {
+			msg, err := p.frontend.RecvMultipart(0)
+			if err != nil {
+				p.err = err
+				return
+			}
+			data := copyMultipart(msg)
+			p.store(data)
+			err = p.backend.SendMultipart(data, 0)
+			if err != nil {
+				p.err = err
+				return
+			}
+		}
+		if items[1].REvents&Pollin != 0 {
+			event, err := p.backend.RecvSubscription(0)
+			if err == ErrInvalidSubscription {
+				continue
+			}
+			if err != nil {
+				p.err = err
+				return
+			}
+			if event.Subscribe {
+				err = p.replay(string(event.Topic))
+			}
+			if err != nil {
+				p.err = err
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package zmq
+
+import (
+	"container/list"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLVCProxyReplay(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pub, endpoint: TcpEndpoint}
+	env.setupServer()
+	defer env.destroyEnv()
+	subscriberEndpoint := InprocEndpoint + "_lvc"
+
+	proxy, err := NewLVCProxy(env.Context, TcpEndpoint, subscriberEndpoint)
+	if err != nil {
+		t.Fatal("Error on last value cache proxy creation", err)
+	}
+	proxy.Start()
+	defer proxy.Close()
+
+	// Publish until the proxy is connected and has cached the topics
+	for i := 0; i < 20; i++ {
+		env.server.SendMultipart([][]byte{[]byte("price.a"), []byte("1")}, 0)
+		env.server.SendMultipart([][]byte{[]byte("price.b"), []byte("2")}, 0)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sub, err := env.NewSocket(Sub)
+	if err != nil {
+		t.Fatal("Error on subscriber creation", err)
+	}
+	defer sub.Close()
+	topic := "price.b"
+	sub.SetOptionString(Subscribe, &topic)
+	err = sub.Connect(subscriberEndpoint)
+	if err != nil {
+		t.Fatal("Error on subscriber connect", err)
+	}
+	items := PollItems{&PollItem{Socket: sub, Events: Pollin}}
+	count, err := items.Poll(time.Second)
+	if count != 1 {
+		t.Fatalf("Expected cached value to be replayed, poll returned %d, err %q", count, err)
+	}
+	msg, err := sub.RecvMultipart(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	defer msg.Close()
+	expected := [][]byte{[]byte("price.b"), []byte("2")}
+	if !reflect.DeepEqual(msg.Data, expected) {
+		t.Fatalf("Expected replayed message %q, got %q", expected, msg.Data)
+	}
+}
+
+func TestLVCProxyEviction(t *testing.T) {
+	proxy := &LVCProxy{CacheSize: 2, cache: map[string]*list.Element{}, lru: list.New()}
+	proxy.store([][]byte{[]byte("a"), []byte("1")})
+	proxy.store([][]byte{[]byte("b"), []byte("2")})
+	proxy.store([][]byte{[]byte("a"), []byte("3")})
+	proxy.store([][]byte{[]byte("c"), []byte("4")})
+	if _, ok := proxy.cache["b"]; ok {
+		t.Fatal("Expected least recently updated topic to be evicted")
+	}
+	if len(proxy.cache) != 2 {
+		t.Fatalf("Expected 2 cached topics, got %d", len(proxy.cache))
+	}
+	proxy.MaxAge = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	proxy.expire()
+	if len(proxy.cache) != 0 {
+		t.Fatalf("Expected cached topics to expire, got %d", len(proxy.cache))
+	}
+}
@@ -0,0 +1,326 @@
+// Package metrics counts the messages, bytes and errors of sockets and
+// follows their connections with socket monitors. Counters are exposed as
+// a prometheus collector and through expvar.
+//
+//	collector := metrics.NewCollector()
+//	prometheus.MustRegister(collector)
+//	expvar.Publish("zmq", collector.Expvar())
+//
+//	soc, err := collector.Instrument(rawSocket, "frontend")
+//	err = soc.Watch(ctx)
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// Labels of the errors counted separately, others are counted as "other"
+var errorNames = map[syscall.Errno]string{
+	syscall.EAGAIN:       "EAGAIN",
+	syscall.EINTR:        "EINTR",
+	syscall.EHOSTUNREACH: "EHOSTUNREACH",
+	syscall.ENOTSOCK:     "ENOTSOCK",
+	syscall.EINVAL:       "EINVAL",
+	zmq.ErrFSM:           "EFSM",
+	zmq.ErrTerminated:    "ETERM",
+}
+
+// Interval between two checks of the end of the monitoring
+const watchInterval = 100 * time.Millisecond
+
+// Sequence of the monitor endpoints created by Watch
+var monitors uint64
+
+// Stats are the counters of the sockets instrumented with the same
+// name and type
+type Stats struct {
+	Name             string
+	Type             string
+	MessagesSent     uint64
+	MessagesReceived uint64
+	FramesSent       uint64
+	FramesReceived   uint64
+	BytesSent        uint64
+	BytesReceived    uint64
+	// Errors are counted by error name, like EAGAIN when a send would
+	// block on the high water mark or a receive times out
+	SendErrors map[string]uint64
+	RecvErrors map[string]uint64
+	// Connections are followed by the sockets with Watch
+	Connections int64
+	Events      map[string]uint64
+}
+
+type key struct {
+	name       string
+	socketType string
+}
+
+// counters are updated by the sockets. Maps are guarded by the mutex
+// of the collector.
+type counters struct {
+	messagesSent     atomic.Uint64
+	messagesReceived atomic.Uint64
+	framesSent       atomic.Uint64
+	framesReceived   atomic.Uint64
+	bytesSent        atomic.Uint64
+	bytesReceived    atomic.Uint64
+	connections      atomic.Int64
+	sendErrors       map[string]uint64
+	recvErrors       map[string]uint64
+	events           map[string]uint64
+}
+
+// Collector holds the counters of instrumented sockets
+type Collector struct {
+	mu      sync.Mutex
+	sockets map[key]*counters
+}
+
+// NewCollector creates a collector without sockets
+func NewCollector() *Collector {
+	return &Collector{sockets: map[key]*counters{}}
+}
+
+// Instrument wraps a socket to count its traffic. Sockets instrumented
+// with the same name and type share their counters.
+func (c *Collector) Instrument(soc *zmq.Socket, name string) (*Socket, error) {
+	socketType, err := soc.GetOptionInt(zmq.Type)
+	if err != nil {
+		return nil, err
+	}
+	// Socket types are labelled in lower case
+	k := key{name: name, socketType: strings.ToLower(zmq.SocketType(socketType).String())}
+	c.mu.Lock()
+	counts, ok := c.sockets[k]
+	if !ok {
+		counts = &counters{
+			sendErrors: map[string]uint64{},
+			recvErrors: map[string]uint64{},
+			events:     map[string]uint64{},
+		}
+		c.sockets[k] = counts
+	}
+	c.mu.Unlock()
+	return &Socket{Socket: soc, collector: c, counters: counts}, nil
+}
+
+// Stats returns the counters of the sockets sorted by name and type
+func (c *Collector) Stats() []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make([]Stats, 0, len(c.sockets))
+	for k, counts := range c.sockets {
+		stats = append(stats, Stats{
+			Name:             k.name,
+			Type:             k.socketType,
+			MessagesSent:     counts.messagesSent.Load(),
+			MessagesReceived: counts.messagesReceived.Load(),
+			FramesSent:       counts.framesSent.Load(),
+			FramesReceived:   counts.framesReceived.Load(),
+			BytesSent:        counts.bytesSent.Load(),
+			BytesReceived:    counts.bytesReceived.Load(),
+			SendErrors:       copyCounts(counts.sendErrors),
+			RecvErrors:       copyCounts(counts.recvErrors),
+			Connections:      counts.connections.Load(),
+			Events:           copyCounts(counts.events),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Name != stats[j].Name {
+			return stats[i].Name < stats[j].Name
+		}
+		return stats[i].Type < stats[j].Type
+	})
+	return stats
+}
+
+// Expvar returns a variable publishing the stats of the sockets
+func (c *Collector) Expvar() expvar.Var {
+	return expvar.Func(func() interface{} { return c.Stats() })
+}
+
+func copyCounts(counts map[string]uint64) map[string]uint64 {
+	copied := make(map[string]uint64, len(counts))
+	for k, v := range counts {
+		copied[k] = v
+	}
+	return copied
+}
+
+// count increments a counter of a map guarded by the collector mutex
+func (c *Collector) count(counts map[string]uint64, label string) {
+	c.mu.Lock()
+	counts[label]++
+	c.mu.Unlock()
+}
+
+// errorName returns the label of an error
+func errorName(err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if name, ok := errorNames[errno]; ok {
+			return name
+		}
+	}
+	return "other"
+}
+
+// Socket counts the messages sent and received with Send, Recv,
+// SendMultipart and RecvMultipart. Other methods are the ones of the
+// wrapped socket and are not counted.
+type Socket struct {
+	*zmq.Socket
+	collector *Collector
+	counters  *counters
+	// Connections of this socket, removed from the shared gauge on close
+	connections int64
+	done        chan struct{}
+	stopped     chan struct{}
+}
+
+// Send sends a frame, the message is counted with its last frame
+func (s *Socket) Send(data []byte, flag zmq.SendFlag) error {
+	err := s.Socket.Send(data, flag)
+	if err != nil {
+		s.collector.count(s.counters.sendErrors, errorName(err))
+		return err
+	}
+	s.counters.framesSent.Add(1)
+	s.counters.bytesSent.Add(uint64(len(data)))
+	if flag&zmq.SndMore == 0 {
+		s.counters.messagesSent.Add(1)
+	}
+	return nil
+}
+
+// Recv receives a frame, the message is counted with its last frame
+func (s *Socket) Recv(flag zmq.SendFlag) (*zmq.MessagePart, error) {
+	part, err := s.Socket.Recv(flag)
+	if err != nil {
+		s.collector.count(s.counters.recvErrors, errorName(err))
+		return nil, err
+	}
+	s.counters.framesReceived.Add(1)
+	s.counters.bytesReceived.Add(uint64(len(part.Data)))
+	if !part.HasMore() {
+		s.counters.messagesReceived.Add(1)
+	}
+	return part, nil
+}
+
+// SendMultipart sends a message with one or several frames
+func (s *Socket) SendMultipart(data [][]byte, flag zmq.SendFlag) error {
+	for i, frame := range data {
+		frameFlag := flag
+		if i < len(data)-1 {
+			frameFlag |= zmq.SndMore
+		}
+		err := s.Send(frame, frameFlag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecvMultipart receives a message with one or several frames
+func (s *Socket) RecvMultipart(flag zmq.SendFlag) (*zmq.MessageMultipart, error) {
+	msg, err := s.Socket.RecvMultipart(flag)
+	if err != nil {
+		s.collector.count(s.counters.recvErrors, errorName(err))
+		return nil, err
+	}
+	s.counters.messagesReceived.Add(1)
+	s.counters.framesReceived.Add(uint64(len(msg.Data)))
+	for _, frame := range msg.Data {
+		s.counters.bytesReceived.Add(uint64(len(frame)))
+	}
+	return msg, nil
+}
+
+// Watch monitors the socket to count its events and connections.
+// The monitor uses a pair socket of the context of the socket,
+// closed with the socket.
+func (s *Socket) Watch(ctx *zmq.Context) error {
+	if s.done != nil {
+		return errors.New("metrics: socket already watched")
+	}
+	endpoint := fmt.Sprintf("inproc://metrics-monitor-%d", atomic.AddUint64(&monitors, 1))
+	err := s.Socket.Monitor(endpoint, zmq.EventAll)
+	if err != nil {
+		return err
+	}
+	pair, err := ctx.NewSocket(zmq.Pair)
+	if err != nil {
+		return err
+	}
+	err = pair.Connect(endpoint)
+	if err != nil {
+		pair.Close()
+		return err
+	}
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.watch(pair)
+	return nil
+}
+
+// watch receives the monitor events until the socket is closed
+// or the context terminated
+func (s *Socket) watch(pair *zmq.Socket) {
+	defer close(s.stopped)
+	defer pair.Close()
+	items := zmq.PollItems{&zmq.PollItem{Socket: pair, Events: zmq.Pollin}}
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		n, err := items.Poll(watchInterval)
+		if err != nil {
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		event, err := pair.RecvMonitorEvent(0)
+		if err == zmq.ErrInvalidMonitorEvent {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		s.collector.count(s.counters.events, event.Event.String())
+		switch event.Event {
+		case zmq.EventConnected, zmq.EventAccepted:
+			atomic.AddInt64(&s.connections, 1)
+			s.counters.connections.Add(1)
+		case zmq.EventDisconnected:
+			atomic.AddInt64(&s.connections, -1)
+			s.counters.connections.Add(-1)
+		}
+	}
+}
+
+// Close stops the monitoring and closes the socket
+func (s *Socket) Close() error {
+	if s.done != nil {
+		close(s.done)
+		<-s.stopped
+		s.done = nil
+	}
+	s.counters.connections.Add(-atomic.SwapInt64(&s.connections, 0))
+	return s.Socket.Close()
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"github.com/bonnefoa/go-zeromq/zmqtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newSocket(t *testing.T, ctx *zmq.Context, c *Collector, socketType zmq.SocketType, name string) *Socket {
+	raw, err := ctx.NewSocket(socketType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.SetOptionInt(zmq.Linger, 0)
+	soc, err := c.Instrument(raw, name)
+	if err != nil {
+		t.Fatal("Error on instrument", err)
+	}
+	err = soc.Watch(ctx)
+	if err != nil {
+		t.Fatal("Error on watch", err)
+	}
+	return soc
+}
+
+// waitStats waits for the stats of the socket to match the condition
+func waitStats(t *testing.T, c *Collector, name string, cond func(Stats) bool) Stats {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, s := range c.Stats() {
+			if s.Name == name && cond(s) {
+				return s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Unexpected stats %+v", c.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	ctx, _ := zmq.NewContext()
+	defer ctx.Destroy()
+	c := NewCollector()
+	pull := newSocket(t, ctx, c, zmq.Pull, "backend")
+	defer pull.Close()
+	push := newSocket(t, ctx, c, zmq.Push, "frontend")
+	defer push.Close()
+	endpoint := zmqtest.TCPEndpoint(t)
+	pull.Bind(endpoint)
+	push.Connect(endpoint)
+
+	err := push.SendMultipart([][]byte{[]byte("ab"), []byte("cde")}, 0)
+	if err != nil {
+		t.Fatal("Error on send", err)
+	}
+	push.Send([]byte("f"), 0)
+	msg, err := pull.RecvMultipart(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	msg.Close()
+	part, err := pull.Recv(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	part.Close()
+	pull.SetOptionInt(zmq.Rcvtimeo, 10)
+	_, err = pull.Recv(0)
+	if err == nil {
+		t.Fatal("Expected receive timeout")
+	}
+
+	sent := waitStats(t, c, "frontend", func(s Stats) bool { return s.Connections == 1 })
+	if sent.Type != "push" || sent.MessagesSent != 2 || sent.FramesSent != 3 || sent.BytesSent != 6 {
+		t.Fatalf("Unexpected send stats %+v", sent)
+	}
+	received := waitStats(t, c, "backend", func(s Stats) bool { return s.Connections == 1 })
+	if received.MessagesReceived != 2 || received.FramesReceived != 3 || received.BytesReceived != 6 {
+		t.Fatalf("Unexpected receive stats %+v", received)
+	}
+	if received.RecvErrors["EAGAIN"] != 1 || received.Events["listening"] != 1 || received.Events["accepted"] != 1 {
+		t.Fatalf("Expected timeout and events, got %+v", received)
+	}
+
+	expected := `
+# HELP zmq_messages_total Messages sent or received by the sockets.
+# TYPE zmq_messages_total counter
+zmq_messages_total{direction="received",socket="backend",type="pull"} 2
+zmq_messages_total{direction="received",socket="frontend",type="push"} 0
+zmq_messages_total{direction="sent",socket="backend",type="pull"} 0
+zmq_messages_total{direction="sent",socket="frontend",type="push"} 2
+# HELP zmq_connections Connections of the watched sockets.
+# TYPE zmq_connections gauge
+zmq_connections{socket="backend",type="pull"} 1
+zmq_connections{socket="frontend",type="push"} 1
+`
+	err = testutil.CollectAndCompare(c, strings.NewReader(expected), "zmq_messages_total", "zmq_connections")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	err = registry.Register(c)
+	if err != nil {
+		t.Fatal("Error on register", err)
+	}
+
+	push.Close()
+	waitStats(t, c, "frontend", func(s Stats) bool { return s.Connections == 0 })
+	waitStats(t, c, "backend", func(s Stats) bool { return s.Connections == 0 })
+}
+
+func TestExpvar(t *testing.T) {
+	c := NewCollector()
+	ctx, _ := zmq.NewContext()
+	defer ctx.Destroy()
+	raw, _ := ctx.NewSocket(zmq.Dealer)
+	soc, err := c.Instrument(raw, "client")
+	if err != nil {
+		t.Fatal("Error on instrument", err)
+	}
+	defer soc.Close()
+	v := c.Expvar()
+	if !strings.Contains(v.String(), `"Name":"client","Type":"dealer"`) {
+		t.Fatalf("Unexpected expvar %s", v.String())
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	socketLabels    = []string{"socket", "type"}
+	messagesDesc    = prometheus.NewDesc("zmq_messages_total", "Messages sent or received by the sockets.", append(socketLabels, "direction"), nil)
+	framesDesc      = prometheus.NewDesc("zmq_frames_total", "Frames sent or received by the sockets.", append(socketLabels, "direction"), nil)
+	bytesDesc       = prometheus.NewDesc("zmq_bytes_total", "Bytes sent or received by the sockets.", append(socketLabels, "direction"), nil)
+	errorsDesc      = prometheus.NewDesc("zmq_errors_total", "Errors of sends or receives by error name.", append(socketLabels, "direction", "error"), nil)
+	connectionsDesc = prometheus.NewDesc("zmq_connections", "Connections of the watched sockets.", socketLabels, nil)
+	eventsDesc      = prometheus.NewDesc("zmq_monitor_events_total", "Monitor events of the watched sockets.", append(socketLabels, "event"), nil)
+)
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- messagesDesc
+	ch <- framesDesc
+	ch <- bytesDesc
+	ch <- errorsDesc
+	ch <- connectionsDesc
+	ch <- eventsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.Stats() {
+		counter := func(desc *prometheus.Desc, value uint64, labels ...string) {
+			labels = append([]string{s.Name, s.Type}, labels...)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labels...)
+		}
+		counter(messagesDesc, s.MessagesSent, "sent")
+		counter(messagesDesc, s.MessagesReceived, "received")
+		counter(framesDesc, s.FramesSent, "sent")
+		counter(framesDesc, s.FramesReceived, "received")
+		counter(bytesDesc, s.BytesSent, "sent")
+		counter(bytesDesc, s.BytesReceived, "received")
+		for name, value := range s.SendErrors {
+			counter(errorsDesc, value, "sent", name)
+		}
+		for name, value := range s.RecvErrors {
+			counter(errorsDesc, value, "received", name)
+		}
+		for event, value := range s.Events {
+			counter(eventsDesc, value, event)
+		}
+		ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(s.Connections), s.Name, s.Type)
+	}
+}
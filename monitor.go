@@ -0,0 +1,75 @@
+package zmq
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidMonitorEvent is returned when a message received from a socket
+// monitor is not an event
+var ErrInvalidMonitorEvent = errors.New("zmq: invalid monitor event")
+
+// Size of the header of monitor events, with the event and its value
+const monitorHeaderSize = 6
+
+var socketEventNames = map[SocketEvent]string{
+	EventConnected:      "connected",
+	EventConnectDelayed: "connect_delayed",
+	EventConnectRetried: "connect_retried",
+	EventListening:      "listening",
+	EventBindFailed:     "bind_failed",
+	EventAccepted:       "accepted",
+	EventAcceptFailed:   "accept_failed",
+	EventClosed:         "closed",
+	EventCloseFailed:    "close_failed",
+	EventDisconnected:   "disconnected",
+}
+
+func (e SocketEvent) String() string {
+	if name, ok := socketEventNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("event_%#x", int(e))
+}
+
+// MonitorEvent is an event reported by a socket monitor
+type MonitorEvent struct {
+	Event SocketEvent
+	// Value is a file descriptor, an error number or a reconnection
+	// interval depending on the event
+	Value    int
+	Endpoint string
+}
+
+// ParseMonitorEvent decodes the frames of a monitor message. The first
+// frame holds the event and its value in little endian, the second one
+// the endpoint.
+func ParseMonitorEvent(frames [][]byte) (MonitorEvent, error) {
+	if len(frames) == 0 || len(frames[0]) < monitorHeaderSize {
+		return MonitorEvent{}, ErrInvalidMonitorEvent
+	}
+	event := MonitorEvent{
+		Event: SocketEvent(binary.LittleEndian.Uint16(frames[0])),
+		Value: int(int32(binary.LittleEndian.Uint32(frames[0][2:]))),
+	}
+	if len(frames) > 1 {
+		event.Endpoint = string(frames[1])
+	}
+	return event, nil
+}
+
+// RecvMonitorEvent receives the next event on a pair socket connected
+// to the endpoint given to Monitor
+func (s *Socket) RecvMonitorEvent(flag SendFlag) (MonitorEvent, error) {
+	msg, err := s.RecvMultipart(flag)
+	if err != nil {
+		return MonitorEvent{}, err
+	}
+	event, err := ParseMonitorEvent(copyMultipart(msg))
+	if err != nil {
+		return event, err
+	}
+	s.logMonitorEvent(event)
+	return event, nil
+}
@@ -0,0 +1,46 @@
+package zmq
+
+import (
+	"testing"
+)
+
+func TestRecvMonitorEvent(t *testing.T) {
+	env := &Env{Tester: t, serverType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+	monitorEndpoint := "inproc://monitor_event"
+	env.server.Monitor(monitorEndpoint, EventListening)
+	monitor, err := env.NewSocket(Pair)
+	if err != nil {
+		t.Fatal("Error when creating new monitor pair socket", err)
+	}
+	defer monitor.Close()
+	monitor.Connect(monitorEndpoint)
+
+	env.server.Bind(TcpEndpoint)
+	event, err := monitor.RecvMonitorEvent(0)
+	if err != nil {
+		t.Fatal("Error when receiving monitor event", err)
+	}
+	if event.Event != EventListening || event.Endpoint != TcpEndpoint {
+		t.Fatalf("Expected listening event on %s, got %+v", TcpEndpoint, event)
+	}
+	if event.Event.String() != "listening" {
+		t.Fatalf("Expected listening, got %s", event.Event)
+	}
+	env.server.Unbind(TcpEndpoint)
+}
+
+func TestParseMonitorEvent(t *testing.T) {
+	event, err := ParseMonitorEvent([][]byte{{0x00, 0x02, 0xff, 0xff, 0xff, 0xff}})
+	if err != nil {
+		t.Fatal("Error on parse", err)
+	}
+	if event.Event != EventDisconnected || event.Value != -1 || event.Endpoint != "" {
+		t.Fatalf("Unexpected event %+v", event)
+	}
+	_, err = ParseMonitorEvent([][]byte{{0x01}})
+	if err != ErrInvalidMonitorEvent {
+		t.Fatalf("Expected invalid event, got %v", err)
+	}
+}
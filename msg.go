@@ -1,3 +1,5 @@
+//go:build !purego
+
 package zmq
 
 /*
@@ -15,46 +17,6 @@ import (
 
 type zmqMsg C.zmq_msg_t
 
-// MessageMultipart represents a multipart frame message
-type MessageMultipart struct {
-	parts []*MessagePart
-	Data  [][]byte
-}
-
-// MessagePart represents a single message frame
-type MessagePart struct {
-	Data []byte
-	*zmqMsg
-}
-
-func (m *MessageMultipart) aggregateData() {
-	m.Data = make([][]byte, len(m.parts))
-	for i, part := range m.parts {
-		m.Data[i] = part.Data
-	}
-}
-
-// Close all zmq messages to release data and memory
-func (m *MessageMultipart) Close() error {
-	var err error
-	for _, part := range m.parts {
-		cerr := part.Close()
-		if err == nil {
-			err = cerr
-		}
-	}
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// Close zmq message and put back MessagePart to pool
-func (m *MessagePart) Close() error {
-	return m.zmqMsg.Close()
-}
-
 // Close zmq message to release data and memory
 func (m *zmqMsg) Close() error {
 	rc, err := C.zmq_msg_close((*C.zmq_msg_t)(m))
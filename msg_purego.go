@@ -0,0 +1,38 @@
+//go:build purego
+
+package zmq
+
+import (
+	"encoding/binary"
+)
+
+type zmqMsg struct {
+	data []byte
+	more bool
+}
+
+// zmqEvent mirrors the event header sent by socket monitors
+type zmqEvent struct {
+	event uint16
+	value int32
+}
+
+// Close zmq message to release data and memory
+func (m *zmqMsg) Close() error {
+	return nil
+}
+
+// Check if there are more message to fetch
+func (m *zmqMsg) HasMore() bool {
+	return m.more
+}
+
+// Get event of the given message
+func (m *zmqMsg) GetEvent() *zmqEvent {
+	var event zmqEvent
+	if len(m.data) >= 6 {
+		event.event = binary.LittleEndian.Uint16(m.data)
+		event.value = int32(binary.LittleEndian.Uint32(m.data[2:]))
+	}
+	return &event
+}
@@ -0,0 +1,121 @@
+package zmq
+
+// MessageMultipart represents a multipart frame message
+type MessageMultipart struct {
+	parts []*MessagePart
+	Data  [][]byte
+}
+
+// MessagePart represents a single message frame
+type MessagePart struct {
+	Data []byte
+	*zmqMsg
+	// more is the flag of parts created without zmq message
+	more bool
+}
+
+// NewMessagePart creates a message part holding data managed by the
+// garbage collector, for fake sockets
+func NewMessagePart(data []byte, more bool) *MessagePart {
+	return &MessagePart{Data: data, more: more}
+}
+
+// NewMessageMultipart creates a message holding frames managed by the
+// garbage collector, for fake sockets
+func NewMessageMultipart(frames [][]byte) *MessageMultipart {
+	msg := &MessageMultipart{parts: make([]*MessagePart, len(frames))}
+	for i, frame := range frames {
+		msg.parts[i] = NewMessagePart(frame, i < len(frames)-1)
+	}
+	msg.aggregateData()
+	return msg
+}
+
+func (m *MessageMultipart) aggregateData() {
+	m.Data = make([][]byte, len(m.parts))
+	for i, part := range m.parts {
+		m.Data[i] = part.Data
+	}
+}
+
+// Copy frames data to memory managed by the garbage collector
+// and close the zmq messages
+func copyMultipart(m *MessageMultipart) [][]byte {
+	data := make([][]byte, len(m.Data))
+	for i, part := range m.Data {
+		data[i] = make([]byte, len(part))
+		copy(data[i], part)
+	}
+	m.Close()
+	return data
+}
+
+// Close all zmq messages to release data and memory
+func (m *MessageMultipart) Close() error {
+	var err error
+	for _, part := range m.parts {
+		cerr := part.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close zmq message and put back MessagePart to pool
+func (m *MessagePart) Close() error {
+	if m.zmqMsg == nil {
+		return nil
+	}
+	return m.zmqMsg.Close()
+}
+
+// HasMore reports whether more parts of the message follow
+func (m *MessagePart) HasMore() bool {
+	if m.zmqMsg == nil {
+		return m.more
+	}
+	return m.zmqMsg.HasMore()
+}
+
+// SendMultipart sends a message with on or several frames to the socket
+func (s *Socket) SendMultipart(data [][]byte, flag SendFlag) error {
+	moreFlag := flag | SndMore
+	for _, v := range data[:len(data)-1] {
+		err := s.Send(v, moreFlag)
+		if err != nil {
+			return err
+		}
+	}
+	err := s.Send(data[len(data)-1], flag)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RecvMultipart receives a multi part message from the socket
+func (s *Socket) RecvMultipart(flag SendFlag) (*MessageMultipart, error) {
+	msg := &MessageMultipart{}
+	msg.parts = make([]*MessagePart, 0, 10)
+	i := 0
+	for {
+		msgPart, err := s.Recv(flag)
+		if err != nil {
+			return nil, err
+		}
+		msg.parts = append(msg.parts, msgPart)
+		i += 1
+		if !msgPart.HasMore() {
+			break
+		}
+	}
+	// Make slice iterable
+	msg.parts = msg.parts[:i]
+	msg.aggregateData()
+	return msg, nil
+}
@@ -0,0 +1,367 @@
+package zmq
+
+import (
+	"time"
+)
+
+// Paranoid Pirate protocol commands exchanged between queue and workers
+const (
+	ppReady     = "\x01"
+	ppHeartbeat = "\x02"
+)
+
+// Default settings of the Paranoid Pirate queue and worker
+const (
+	DefaultHeartbeatInterval = time.Second
+	DefaultHeartbeatLiveness = 3
+	DefaultReconnectMin      = time.Second
+	DefaultReconnectMax      = 32 * time.Second
+)
+
+// RequestHandler computes the reply of a request received by a worker
+type RequestHandler func(request [][]byte) [][]byte
+
+// A worker known by the queue with its expiration date
+type ppWorker struct {
+	identity []byte
+	expiry   time.Time
+}
+
+// PPQueue is a load balancing broker implementing the Paranoid Pirate pattern.
+// Clients connect to the frontend router and workers to the backend router.
+// Requests are dispatched to the least recently used worker, and workers
+// which stopped sending heartbeats are purged from the queue.
+type PPQueue struct {
+	// HeartbeatInterval is the delay between two heartbeats sent to workers
+	HeartbeatInterval time.Duration
+	// HeartbeatLiveness is the number of missed heartbeats before a worker
+	// is considered dead
+	HeartbeatLiveness int
+
+	frontend *Socket
+	backend  *Socket
+	workers  []*ppWorker
+	err      error
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewPPQueue creates a queue with its frontend and backend router sockets
+// bound to the given endpoints
+func NewPPQueue(ctx *Context, frontendEndpoint, backendEndpoint string) (*PPQueue, error) {
+	q := &PPQueue{
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		HeartbeatLiveness: DefaultHeartbeatLiveness,
+	}
+	var err error
+	q.frontend, err = ctx.NewSocket(Router)
+	if err != nil {
+		return nil, err
+	}
+	q.backend, err = ctx.NewSocket(Router)
+	if err != nil {
+		q.frontend.Close()
+		return nil, err
+	}
+	err = q.frontend.Bind(frontendEndpoint)
+	if err == nil {
+		err = q.backend.Bind(backendEndpoint)
+	}
+	if err != nil {
+		q.closeSockets()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Start routing messages between clients and workers in a new goroutine
+func (q *PPQueue) Start() {
+	q.done = make(chan struct{})
+	q.stopped = make(chan struct{})
+	go q.run()
+}
+
+// Close stops the queue and closes its sockets.
+// The error which interrupted the queue, if any, is returned.
+func (q *PPQueue) Close() error {
+	if q.done != nil {
+		close(q.done)
+		<-q.stopped
+	}
+	err := q.closeSockets()
+	if q.err != nil {
+		return q.err
+	}
+	return err
+}
+
+func (q *PPQueue) closeSockets() error {
+	q.frontend.SetOptionInt(Linger, 0)
+	q.backend.SetOptionInt(Linger, 0)
+	err := q.frontend.Close()
+	berr := q.backend.Close()
+	if err == nil {
+		err = berr
+	}
+	return err
+}
+
+// Move the worker at the end of the ready list and refresh its expiry
+func (q *PPQueue) workerReady(identity []byte) {
+	q.removeWorker(identity)
+	expiry := time.Now().Add(q.HeartbeatInterval * time.Duration(q.HeartbeatLiveness))
+	q.workers = append(q.workers, &ppWorker{identity: identity, expiry: expiry})
+}
+
+func (q *PPQueue) removeWorker(identity []byte) {
+	for i, worker := range q.workers {
+		if string(worker.identity) == string(identity) {
+			q.workers = append(q.workers[:i], q.workers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Remove expired workers, the oldest workers are at the start of the list
+func (q *PPQueue) purge() {
+	now := time.Now()
+	for len(q.workers) > 0 && now.After(q.workers[0].expiry) {
+		q.workers = q.workers[1:]
+	}
+}
+
+func (q *PPQueue) run() {
+	defer close(q.stopped)
+	heartbeatAt := time.Now().Add(q.HeartbeatInterval)
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+		// Only poll clients when a worker is available
+		items := PollItems{&PollItem{Socket: q.backend, Events: Pollin}}
+		if len(q.workers) > 0 {
+			items = append(items, &PollItem{Socket: q.frontend, Events: Pollin})
+		}
+		_, err := items.Poll(q.HeartbeatInterval)
+		if err != nil {
+			q.err = err
+			return
+		}
+
+		if items[0].REvents&Pollin != 0 {
+			msg, err := q.backend.RecvMultipart(0)
+			if err != nil {
+				q.err = err
+				return
+			}
+			data := copyMultipart(msg)
+			if len(data) < 2 {
+				continue
+			}
+			// Any message from a worker means it is ready
+			q.workerReady(data[0])
+			body := data[1:]
+			if len(body) > 1 {
+				err = q.frontend.SendMultipart(body, 0)
+				if err != nil {
+					q.err = err
+					return
+				}
+			}
+		}
+		if len(items) > 1 && items[1].REvents&Pollin != 0 {
+			msg, err := q.frontend.RecvMultipart(0)
+			if err != nil {
+				q.err = err
+				return
+			}
+			worker := q.workers[0]
+			q.workers = q.workers[1:]
+			data := append([][]byte{worker.identity}, copyMultipart(msg)...)
+			err = q.backend.SendMultipart(data, 0)
+			if err != nil {
+				q.err = err
+				return
+			}
+		}
+
+		if time.Now().After(heartbeatAt) {
+			for _, worker := range q.workers {
+				err = q.backend.SendMultipart([][]byte{worker.identity, []byte(ppHeartbeat)}, 0)
+				if err != nil {
+					q.err = err
+					return
+				}
+			}
+			heartbeatAt = time.Now().Add(q.HeartbeatInterval)
+		}
+		q.purge()
+	}
+}
+
+// PPWorker is a worker implementing the Paranoid Pirate pattern.
+// It exchanges heartbeats with the queue and reconnects, with an exponential
+// back-off, when the queue stops answering.
+type PPWorker struct {
+	// HeartbeatInterval is the delay between two heartbeats sent to the queue
+	HeartbeatInterval time.Duration
+	// HeartbeatLiveness is the number of missed heartbeats before the queue
+	// is considered dead
+	HeartbeatLiveness int
+	// ReconnectMin is the initial delay before reconnecting to the queue
+	ReconnectMin time.Duration
+	// ReconnectMax is the maximum delay before reconnecting to the queue
+	ReconnectMax time.Duration
+
+	ctx      *Context
+	endpoint string
+	handler  RequestHandler
+	socket   *Socket
+	err      error
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewPPWorker creates a worker connected to the queue backend at the given
+// endpoint. Requests are answered with the given handler.
+func NewPPWorker(ctx *Context, endpoint string, handler RequestHandler) (*PPWorker, error) {
+	w := &PPWorker{
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		HeartbeatLiveness: DefaultHeartbeatLiveness,
+		ReconnectMin:      DefaultReconnectMin,
+		ReconnectMax:      DefaultReconnectMax,
+		ctx:               ctx,
+		endpoint:          endpoint,
+		handler:           handler,
+	}
+	err := w.connect()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Create a new dealer socket, connect it and signal the queue
+// that the worker is ready
+func (w *PPWorker) connect() error {
+	soc, err := w.ctx.NewSocket(Dealer)
+	if err != nil {
+		return err
+	}
+	err = soc.Connect(w.endpoint)
+	if err == nil {
+		err = soc.Send([]byte(ppReady), 0)
+	}
+	if err != nil {
+		soc.SetOptionInt(Linger, 0)
+		soc.Close()
+		return err
+	}
+	w.socket = soc
+	return nil
+}
+
+func (w *PPWorker) disconnect() error {
+	if w.socket == nil {
+		return nil
+	}
+	w.socket.SetOptionInt(Linger, 0)
+	err := w.socket.Close()
+	w.socket = nil
+	return err
+}
+
+// Start processing requests in a new goroutine
+func (w *PPWorker) Start() {
+	w.done = make(chan struct{})
+	w.stopped = make(chan struct{})
+	go w.run()
+}
+
+// Close stops the worker and closes its socket.
+// The error which interrupted the worker, if any, is returned.
+func (w *PPWorker) Close() error {
+	if w.done != nil {
+		close(w.done)
+		<-w.stopped
+	}
+	err := w.disconnect()
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+func (w *PPWorker) run() {
+	defer close(w.stopped)
+	liveness := w.HeartbeatLiveness
+	reconnect := w.ReconnectMin
+	heartbeatAt := time.Now().Add(w.HeartbeatInterval)
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		items := PollItems{&PollItem{Socket: w.socket, Events: Pollin}}
+		_, err := items.Poll(w.HeartbeatInterval)
+		if err != nil {
+			w.err = err
+			return
+		}
+
+		if items[0].REvents&Pollin != 0 {
+			msg, err := w.socket.RecvMultipart(0)
+			if err != nil {
+				w.err = err
+				return
+			}
+			data := copyMultipart(msg)
+			switch {
+			case len(data) >= 3:
+				// Request with its reply envelope: [client, "", request...]
+				reply := append([][]byte{data[0], data[1]}, w.handler(data[2:])...)
+				err = w.socket.SendMultipart(reply, 0)
+				if err != nil {
+					w.err = err
+					return
+				}
+				liveness = w.HeartbeatLiveness
+			case len(data) == 1 && string(data[0]) == ppHeartbeat:
+				liveness = w.HeartbeatLiveness
+			}
+			reconnect = w.ReconnectMin
+		} else {
+			liveness--
+			if liveness == 0 {
+				select {
+				case <-w.done:
+					return
+				case <-time.After(reconnect):
+				}
+				reconnect *= 2
+				if reconnect > w.ReconnectMax {
+					reconnect = w.ReconnectMax
+				}
+				w.disconnect()
+				err = w.connect()
+				if err != nil {
+					w.err = err
+					return
+				}
+				liveness = w.HeartbeatLiveness
+			}
+		}
+
+		if time.Now().After(heartbeatAt) {
+			heartbeatAt = time.Now().Add(w.HeartbeatInterval)
+			err = w.socket.Send([]byte(ppHeartbeat), 0)
+			if err != nil {
+				w.err = err
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParanoidPirate(t *testing.T) {
+	env := &Env{Tester: t}
+	env.setupEnv()
+	defer env.destroyEnv()
+	backendEndpoint := InprocEndpoint + "_ppqueue"
+
+	queue, err := NewPPQueue(env.Context, TcpEndpoint, backendEndpoint)
+	if err != nil {
+		t.Fatal("Error on queue creation", err)
+	}
+	queue.HeartbeatInterval = 100 * time.Millisecond
+	queue.Start()
+	defer queue.Close()
+
+	worker, err := NewPPWorker(env.Context, backendEndpoint, func(request [][]byte) [][]byte {
+		return append(request, []byte("done"))
+	})
+	if err != nil {
+		t.Fatal("Error on worker creation", err)
+	}
+	worker.HeartbeatInterval = 100 * time.Millisecond
+	worker.Start()
+	defer worker.Close()
+
+	client, err := NewReliableReq(env.Context, TcpEndpoint, time.Second, 3)
+	if err != nil {
+		t.Fatal("Error on reliable request client creation", err)
+	}
+	defer client.Close()
+	for i := 0; i < 3; i++ {
+		reply, err := client.Request([][]byte{[]byte("work")})
+		if err != nil {
+			t.Fatal("Error on request", err)
+		}
+		expected := [][]byte{[]byte("work"), []byte("done")}
+		if !reflect.DeepEqual(reply, expected) {
+			t.Fatalf("Expected reply %q, got %q", expected, reply)
+		}
+	}
+}
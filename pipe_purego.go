@@ -0,0 +1,161 @@
+//go:build purego
+
+package zmq
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// pipeBuffer is one direction of an inproc connection.
+// Writes never block, so both peers can send their greeting at once.
+type pipeBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newPipeBuffer() *pipeBuffer {
+	b := &pipeBuffer{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *pipeBuffer) Read(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if b.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return b.buf.Read(data)
+}
+
+func (b *pipeBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	b.cond.Broadcast()
+	return b.buf.Write(data)
+}
+
+func (b *pipeBuffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+type inprocAddr string
+
+func (a inprocAddr) Network() string { return "inproc" }
+func (a inprocAddr) String() string  { return string(a) }
+
+// pipeConn is an end of an inproc connection
+type pipeConn struct {
+	r, w *pipeBuffer
+	addr inprocAddr
+	// accepted is closed once the bound socket attached the connection
+	accepted chan struct{}
+}
+
+func newPipe(addr string) (*pipeConn, *pipeConn) {
+	a, b := newPipeBuffer(), newPipeBuffer()
+	accepted := make(chan struct{})
+	client := &pipeConn{r: a, w: b, addr: inprocAddr(addr), accepted: accepted}
+	server := &pipeConn{r: b, w: a, addr: inprocAddr(addr), accepted: accepted}
+	return client, server
+}
+
+func (c *pipeConn) Read(data []byte) (int, error)  { return c.r.Read(data) }
+func (c *pipeConn) Write(data []byte) (int, error) { return c.w.Write(data) }
+
+func (c *pipeConn) Close() error {
+	c.r.close()
+	c.w.close()
+	return nil
+}
+
+func (c *pipeConn) LocalAddr() net.Addr                { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr               { return c.addr }
+func (c *pipeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// inprocListener accepts the inproc connections of a bound endpoint
+type inprocListener struct {
+	ctx   *Context
+	name  string
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// listenInproc registers an inproc endpoint in the context
+func (ctx *Context) listenInproc(name string) (net.Listener, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if _, ok := ctx.inproc[name]; ok {
+		return nil, syscall.EADDRINUSE
+	}
+	l := &inprocListener{
+		ctx:   ctx,
+		name:  name,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	ctx.inproc[name] = l
+	return l, nil
+}
+
+// dialInproc connects to a bound inproc endpoint. The returned channel
+// is closed once the bound socket attached the connection.
+func (ctx *Context) dialInproc(name string) (net.Conn, <-chan struct{}, error) {
+	ctx.mu.Lock()
+	l, ok := ctx.inproc[name]
+	ctx.mu.Unlock()
+	if !ok {
+		return nil, nil, syscall.ECONNREFUSED
+	}
+	client, server := newPipe("inproc://" + name)
+	select {
+	case l.conns <- server:
+		return client, client.accepted, nil
+	case <-l.done:
+		return nil, nil, syscall.ECONNREFUSED
+	}
+}
+
+func (l *inprocListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *inprocListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+		l.ctx.mu.Lock()
+		if l.ctx.inproc[l.name] == l {
+			delete(l.ctx.inproc, l.name)
+		}
+		l.ctx.mu.Unlock()
+	})
+	return nil
+}
+
+func (l *inprocListener) Addr() net.Addr {
+	return inprocAddr("inproc://" + l.name)
+}
@@ -0,0 +1,88 @@
+//go:build purego
+
+package zmq
+
+import (
+	"reflect"
+	"time"
+)
+
+type pollEvent int16
+
+// Available polling events
+const (
+	Pollin  = pollEvent(1)
+	Pollout = pollEvent(2)
+	Pollerr = pollEvent(4)
+)
+
+// PollItems agregates multiple poll events
+type PollItems []*PollItem
+
+// PollItem identifies a poll events to wait on a socket
+type PollItem struct {
+	Socket  *Socket
+	Events  pollEvent
+	REvents pollEvent
+}
+
+// Kind of the select cases built by Poll
+const (
+	pollIncoming = iota
+	pollChanged
+	pollTerm
+	pollTimeout
+)
+
+// Poll until timeout or until one or multiple polled events happens
+func (p PollItems) Poll(timeout time.Duration) (int, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		count := 0
+		for _, item := range p {
+			if item.Events&Pollin != 0 && len(item.Socket.recvBuf) == 0 {
+				item.Socket.fill(false, 0)
+			}
+			item.REvents = item.Socket.events() & item.Events
+			if item.REvents != 0 {
+				count++
+			}
+		}
+		if count > 0 || timeout == 0 {
+			return count, nil
+		}
+
+		var cases []reflect.SelectCase
+		var kinds, indexes []int
+		add := func(ch interface{}, kind, index int) {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)})
+			kinds = append(kinds, kind)
+			indexes = append(indexes, index)
+		}
+		for i, item := range p {
+			if item.Events&Pollin != 0 {
+				add(item.Socket.incoming, pollIncoming, i)
+			}
+			if item.Events&Pollout != 0 {
+				add(item.Socket.changed, pollChanged, i)
+			}
+			add(item.Socket.ctx.term, pollTerm, i)
+		}
+		add(expired, pollTimeout, 0)
+
+		chosen, value, _ := reflect.Select(cases)
+		switch kinds[chosen] {
+		case pollIncoming:
+			p[indexes[chosen]].Socket.accept(value.Interface().(inMsg))
+		case pollTerm:
+			return -1, ErrTerminated
+		case pollTimeout:
+			return 0, nil
+		}
+	}
+}
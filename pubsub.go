@@ -0,0 +1,203 @@
+package zmq
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors of typed publish/subscribe
+var (
+	// ErrMalformedPublication is returned when a received message
+	// is not a [topic, envelope, payload] message
+	ErrMalformedPublication = errors.New("zmq: malformed publication")
+	// ErrContentType is returned when a publication was not encoded
+	// with the subscriber codec
+	ErrContentType = errors.New("zmq: unexpected content type")
+)
+
+// Delay between two checks of the subscriber commands
+const subscriberPollInterval = 100 * time.Millisecond
+
+// Publisher publishes values of type T on a pub socket.
+// Each value is sent as a three frames message: the topic, the envelope
+// holding the codec content type and the encoded payload.
+type Publisher[T any] struct {
+	socket *Socket
+	codec  Codec
+}
+
+// NewPublisher creates a publisher sending on the socket with the codec
+func NewPublisher[T any](soc *Socket, codec Codec) *Publisher[T] {
+	return &Publisher[T]{socket: soc, codec: codec}
+}
+
+// Publish encodes and sends the value on the topic
+func (p *Publisher[T]) Publish(topic string, value T) error {
+	payload, err := p.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	data := [][]byte{[]byte(topic), []byte(p.codec.ContentType()), payload}
+	return p.socket.SendMultipart(data, 0)
+}
+
+// Publication is a value received by a subscriber.
+// Err is set when the message could not be decoded.
+type Publication[T any] struct {
+	Topic string
+	Value T
+	Err   error
+}
+
+// Subscription change requested to the receiving goroutine
+type subscriberCommand struct {
+	subscribe bool
+	topic     string
+	result    chan error
+}
+
+// Subscriber receives values of type T sent by a Publisher and delivers
+// them on the C channel. The socket is owned by the subscriber goroutine
+// until Close, subscriptions must go through the subscriber methods.
+// Topics are matched by prefix.
+type Subscriber[T any] struct {
+	// C delivers received publications, it is closed when the subscriber stops
+	C <-chan Publication[T]
+
+	c        chan Publication[T]
+	socket   *Socket
+	codec    Codec
+	commands chan subscriberCommand
+	err      error
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewSubscriber creates a subscriber decoding messages received on the sub
+// socket with the codec and starts receiving in a new goroutine.
+// With a nil codec, the registered codec of each publication content type
+// is used.
+func NewSubscriber[T any](soc *Socket, codec Codec, topics ...string) (*Subscriber[T], error) {
+	for _, topic := range topics {
+		err := soc.Subscribe([]byte(topic))
+		if err != nil {
+			return nil, err
+		}
+	}
+	c := make(chan Publication[T])
+	s := &Subscriber[T]{
+		C:        c,
+		c:        c,
+		socket:   soc,
+		codec:    codec,
+		commands: make(chan subscriberCommand),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Subscribe to the topic
+func (s *Subscriber[T]) Subscribe(topic string) error {
+	return s.command(true, topic)
+}
+
+// Unsubscribe from the topic
+func (s *Subscriber[T]) Unsubscribe(topic string) error {
+	return s.command(false, topic)
+}
+
+func (s *Subscriber[T]) command(subscribe bool, topic string) error {
+	cmd := subscriberCommand{subscribe: subscribe, topic: topic, result: make(chan error, 1)}
+	select {
+	case s.commands <- cmd:
+		return <-cmd.result
+	case <-s.stopped:
+		return s.Err()
+	}
+}
+
+// Err returns the error which stopped the subscriber, if any
+func (s *Subscriber[T]) Err() error {
+	select {
+	case <-s.stopped:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close stops receiving. The socket is not closed.
+func (s *Subscriber[T]) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	<-s.stopped
+	return s.err
+}
+
+// Decode a [topic, envelope, payload] message
+func (s *Subscriber[T]) decode(data [][]byte) Publication[T] {
+	if len(data) != 3 {
+		return Publication[T]{Err: ErrMalformedPublication}
+	}
+	publication := Publication[T]{Topic: string(data[0])}
+	codec := s.codec
+	if codec == nil {
+		var ok bool
+		codec, ok = CodecByContentType(string(data[1]))
+		if !ok {
+			publication.Err = ErrUnknownContentType
+			return publication
+		}
+	}
+	if string(data[1]) != codec.ContentType() {
+		publication.Err = ErrContentType
+		return publication
+	}
+	publication.Err = codec.Unmarshal(data[2], &publication.Value)
+	return publication
+}
+
+func (s *Subscriber[T]) run() {
+	defer close(s.stopped)
+	defer close(s.c)
+	items := PollItems{&PollItem{Socket: s.socket, Events: Pollin}}
+	for {
+		select {
+		case <-s.done:
+			return
+		case cmd := <-s.commands:
+			if cmd.subscribe {
+				cmd.result <- s.socket.Subscribe([]byte(cmd.topic))
+			} else {
+				cmd.result <- s.socket.Unsubscribe([]byte(cmd.topic))
+			}
+			continue
+		default:
+		}
+		_, err := items.Poll(subscriberPollInterval)
+		if err != nil {
+			s.err = err
+			return
+		}
+		if items[0].REvents&Pollin == 0 {
+			continue
+		}
+		msg, err := s.socket.RecvMultipart(0)
+		if err != nil {
+			s.err = err
+			return
+		}
+		publication := s.decode(msg.Data)
+		msg.Close()
+		select {
+		case s.c <- publication:
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testQuote struct {
+	Symbol string
+	Price  float64
+}
+
+func testTypedPubSub(t *testing.T, codec Codec) {
+	env := &Env{Tester: t, serverType: Pub, endpoint: TcpEndpoint, clientType: Sub}
+	env.setupEnv()
+	defer env.destroyEnv()
+
+	subscriber, err := NewSubscriber[testQuote](env.client, codec, "quote.")
+	if err != nil {
+		t.Fatal("Error on subscriber creation", err)
+	}
+	defer subscriber.Close()
+	<-time.After(time.Millisecond * 500)
+
+	publisher := NewPublisher[testQuote](env.server, codec)
+	quote := testQuote{Symbol: "ZMQ", Price: 4.2}
+	err = publisher.Publish("other", testQuote{})
+	if err != nil {
+		t.Fatal("Error on publish", err)
+	}
+	err = publisher.Publish("quote.zmq", quote)
+	if err != nil {
+		t.Fatal("Error on publish", err)
+	}
+	select {
+	case publication := <-subscriber.C:
+		expected := Publication[testQuote]{Topic: "quote.zmq", Value: quote}
+		if !reflect.DeepEqual(publication, expected) {
+			t.Fatalf("Expected publication %+v, got %+v", expected, publication)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No publication received")
+	}
+}
+
+func TestTypedPubSubJSON(t *testing.T) {
+	testTypedPubSub(t, JSONCodec)
+}
+
+func TestTypedPubSubGob(t *testing.T) {
+	testTypedPubSub(t, GobCodec)
+}
+
+func TestSubscriberContentType(t *testing.T) {
+	s := &Subscriber[testQuote]{codec: JSONCodec}
+	payload, _ := GobCodec.Marshal(testQuote{})
+	publication := s.decode([][]byte{[]byte("quote"), []byte(GobCodec.ContentType()), payload})
+	if publication.Err != ErrContentType {
+		t.Fatalf("Expected error %q, got %q", ErrContentType, publication.Err)
+	}
+	publication = s.decode([][]byte{[]byte("quote")})
+	if publication.Err != ErrMalformedPublication {
+		t.Fatalf("Expected error %q, got %q", ErrMalformedPublication, publication.Err)
+	}
+
+	// Without codec, the decoder is selected from the content type
+	s = &Subscriber[testQuote]{}
+	quote := testQuote{Symbol: "ZMQ", Price: 4.2}
+	payload, _ = GobCodec.Marshal(quote)
+	publication = s.decode([][]byte{[]byte("quote"), []byte(GobCodec.ContentType()), payload})
+	if publication.Err != nil || publication.Value != quote {
+		t.Fatalf("Expected decoded value %+v, got %+v", quote, publication)
+	}
+}
@@ -0,0 +1,266 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"github.com/bonnefoa/go-zeromq/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sequence used to build unique inproc endpoints of clients
+var clientSequence uint64
+
+// Client calls methods of a server over a dealer socket.
+// It is safe for concurrent use: calls are forwarded to the client goroutine,
+// the only one using the dealer socket, through an inproc pipe, and replies
+// are matched to calls with their request id.
+type Client struct {
+	codec   zmq.Codec
+	dealer  *zmq.Socket
+	pipeIn  *zmq.Socket
+	pipeOut *zmq.Socket
+	lastID  uint64
+
+	mu      sync.Mutex
+	pending map[uint64]chan [][]byte
+	closed  bool
+
+	err     error
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewClient creates a client connected to the server endpoint.
+// Arguments and replies are encoded with the codec.
+func NewClient(ctx *zmq.Context, endpoint string, codec zmq.Codec) (*Client, error) {
+	c := &Client{
+		codec:   codec,
+		pending: map[uint64]chan [][]byte{},
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	pipe := fmt.Sprintf("inproc://zmq-rpc-client-%d", atomic.AddUint64(&clientSequence, 1))
+	var err error
+	c.dealer, err = ctx.NewSocket(zmq.Dealer)
+	if err == nil {
+		err = c.dealer.Connect(endpoint)
+	}
+	if err == nil {
+		c.pipeOut, err = ctx.NewSocket(zmq.Pull)
+	}
+	if err == nil {
+		err = c.pipeOut.Bind(pipe)
+	}
+	if err == nil {
+		c.pipeIn, err = ctx.NewSocket(zmq.Push)
+	}
+	if err == nil {
+		err = c.pipeIn.Connect(pipe)
+	}
+	if err != nil {
+		c.closeSockets()
+		return nil, err
+	}
+	go c.run()
+	return c, nil
+}
+
+// Call invokes the method, named "Service.Method", with the arguments and
+// decodes its result into reply. The context deadline is sent to the server
+// which drops the request if it expired before processing.
+// Errors sent by the server are of type *Error.
+// The call is traced with a client span whose context is sent to the server.
+func (c *Client) Call(ctx context.Context, method string, args interface{}, reply interface{}) (err error) {
+	ctx, span := startSpan(ctx, method, trace.SpanKindClient)
+	defer func() { endSpan(span, err) }()
+	payload, err := c.codec.Marshal(args)
+	if err != nil {
+		return err
+	}
+	id := atomic.AddUint64(&c.lastID, 1)
+	idFrame := make([]byte, 8)
+	binary.BigEndian.PutUint64(idFrame, id)
+	deadline := make([]byte, 8)
+	if d, ok := ctx.Deadline(); ok {
+		binary.BigEndian.PutUint64(deadline, uint64(d.UnixNano()))
+	}
+	frames := [][]byte{idFrame, []byte(method), deadline, []byte(c.codec.ContentType()), payload}
+	frames = tracing.Inject(ctx, frames)
+
+	result := make(chan [][]byte, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.pending[id] = result
+	err = c.pipeIn.SendMultipart(frames, 0)
+	c.mu.Unlock()
+	if err != nil {
+		c.forget(id)
+		return err
+	}
+
+	select {
+	case frames = <-result:
+	case <-ctx.Done():
+		c.forget(id)
+		return ctx.Err()
+	case <-c.stopped:
+		c.forget(id)
+		if c.err != nil {
+			return c.err
+		}
+		return ErrClosed
+	}
+	// Reply is [status, content type, payload] or [status, code, message]
+	if len(frames) != 3 {
+		return ErrMalformedReply
+	}
+	switch string(frames[0]) {
+	case statusOK:
+		codec, ok := zmq.CodecByContentType(string(frames[1]))
+		if !ok {
+			return zmq.ErrUnknownContentType
+		}
+		return codec.Unmarshal(frames[2], reply)
+	case statusError:
+		return &Error{Code: string(frames[1]), Message: string(frames[2])}
+	}
+	return ErrMalformedReply
+}
+
+func (c *Client) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// Close stops the client, pending calls return ErrClosed
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	close(c.done)
+	<-c.stopped
+	return c.closeSockets()
+}
+
+func (c *Client) closeSockets() error {
+	var err error
+	for _, soc := range []*zmq.Socket{c.pipeIn, c.pipeOut, c.dealer} {
+		if soc == nil {
+			continue
+		}
+		soc.SetOptionInt(zmq.Linger, 0)
+		cerr := soc.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (c *Client) run() {
+	defer close(c.stopped)
+	// Requests waiting for the dealer to accept them without blocking
+	var queue [][][]byte
+	items := zmq.PollItems{
+		&zmq.PollItem{Socket: c.pipeOut, Events: zmq.Pollin},
+		&zmq.PollItem{Socket: c.dealer, Events: zmq.Pollin},
+	}
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		items[1].Events = zmq.Pollin
+		if len(queue) > 0 {
+			items[1].Events |= zmq.Pollout
+		}
+		_, err := items.Poll(pollInterval)
+		if err != nil {
+			c.err = err
+			return
+		}
+		if items[0].REvents&zmq.Pollin != 0 {
+			msg, err := c.pipeOut.RecvMultipart(0)
+			if err != nil {
+				c.err = err
+				return
+			}
+			frames := make([][]byte, len(msg.Data))
+			for i, frame := range msg.Data {
+				frames[i] = append([]byte{}, frame...)
+			}
+			msg.Close()
+			queue = append(queue, frames)
+		}
+		queue, err = c.flush(queue)
+		if err != nil {
+			c.err = err
+			return
+		}
+		if items[1].REvents&zmq.Pollin != 0 {
+			_, body, err := c.dealer.RecvFrom(0)
+			if err != nil {
+				c.err = err
+				return
+			}
+			if len(body) == 0 || len(body[0]) != 8 {
+				continue
+			}
+			id := binary.BigEndian.Uint64(body[0])
+			c.mu.Lock()
+			result, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ok {
+				result <- body[1:]
+			}
+		}
+	}
+}
+
+// flush sends the queued requests until the dealer would block and returns
+// the requests left. Requests of calls which already returned are dropped.
+func (c *Client) flush(queue [][][]byte) ([][][]byte, error) {
+	for len(queue) > 0 {
+		if !c.waiting(queue[0]) {
+			queue = queue[1:]
+			continue
+		}
+		err := c.dealer.SendMultipart(zmq.Envelope(nil).Wrap(queue[0]), zmq.DontWait)
+		if err == syscall.EAGAIN {
+			return queue, nil
+		}
+		if err != nil {
+			return queue, err
+		}
+		queue = queue[1:]
+	}
+	return queue, nil
+}
+
+// waiting reports whether the call of the request still waits for its
+// reply, its context being neither canceled nor past its deadline
+func (c *Client) waiting(request [][]byte) bool {
+	id := binary.BigEndian.Uint64(request[0])
+	c.mu.Lock()
+	_, ok := c.pending[id]
+	c.mu.Unlock()
+	deadline := int64(binary.BigEndian.Uint64(request[2]))
+	return ok && (deadline == 0 || time.Now().UnixNano() < deadline)
+}
@@ -0,0 +1,33 @@
+package rpc
+
+import (
+	"errors"
+)
+
+// Error codes transmitted with server errors
+const (
+	CodeUnknownMethod    = "unknown_method"
+	CodeBadRequest       = "bad_request"
+	CodeDeadlineExceeded = "deadline_exceeded"
+	CodeInternal         = "internal"
+	// CodeApplication is the code of errors returned by methods
+	// which are not of type *Error
+	CodeApplication = "application"
+)
+
+// Client side errors
+var (
+	ErrClosed         = errors.New("rpc: client is closed")
+	ErrMalformedReply = errors.New("rpc: malformed reply")
+)
+
+// Error is an error transmitted by the server to the client.
+// A method can return an *Error to choose the code received by the client.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "rpc: " + e.Code + ": " + e.Message
+}
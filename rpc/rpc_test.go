@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const endpoint = "inproc://rpc_test"
+
+type Args struct {
+	A, B int
+}
+
+type Arith struct{}
+
+func (t *Arith) Add(args Args, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+func (t *Arith) Div(args *Args, reply *int) error {
+	if args.B == 0 {
+		return &Error{Code: "division_by_zero", Message: "divide by zero"}
+	}
+	*reply = args.A / args.B
+	return nil
+}
+
+func (t *Arith) Sleep(d time.Duration, reply *int) error {
+	time.Sleep(d)
+	return errors.New("woke up")
+}
+
+func setup(t *testing.T) (*zmq.Context, *Server, *Client) {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	server, err := NewServer(ctx, endpoint)
+	if err != nil {
+		t.Fatal("Error on server creation", err)
+	}
+	err = server.Register(&Arith{})
+	if err != nil {
+		t.Fatal("Error on service registration", err)
+	}
+	server.Start()
+	client, err := NewClient(ctx, endpoint, zmq.JSONCodec)
+	if err != nil {
+		t.Fatal("Error on client creation", err)
+	}
+	return ctx, server, client
+}
+
+func teardown(ctx *zmq.Context, server *Server, client *Client) {
+	client.Close()
+	server.Close()
+	ctx.Destroy()
+}
+
+func TestCall(t *testing.T) {
+	ctx, server, client := setup(t)
+	defer teardown(ctx, server, client)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var reply int
+			err := client.Call(context.Background(), "Arith.Add", Args{i, 2}, &reply)
+			if err != nil {
+				t.Error("Error on call", err)
+				return
+			}
+			if reply != i+2 {
+				t.Errorf("Expected %d, got %d", i+2, reply)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCallErrors(t *testing.T) {
+	ctx, server, client := setup(t)
+	defer teardown(ctx, server, client)
+
+	var reply int
+	err := client.Call(context.Background(), "Arith.Div", Args{1, 0}, &reply)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != "division_by_zero" {
+		t.Fatalf("Expected division by zero error, got %v", err)
+	}
+	err = client.Call(context.Background(), "Arith.Mul", Args{1, 0}, &reply)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeUnknownMethod {
+		t.Fatalf("Expected unknown method error, got %v", err)
+	}
+	err = client.Call(context.Background(), "Arith.Sleep", time.Duration(0), &reply)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeApplication || rpcErr.Message != "woke up" {
+		t.Fatalf("Expected application error, got %v", err)
+	}
+}
+
+func TestCallDeadline(t *testing.T) {
+	ctx, server, client := setup(t)
+	defer teardown(ctx, server, client)
+
+	callCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var reply int
+	err := client.Call(callCtx, "Arith.Sleep", 200*time.Millisecond, &reply)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	// The queued request expired before being processed
+	callCtx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = client.Call(callCtx, "Arith.Add", Args{1, 2}, &reply)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestCallTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+	ctx, server, client := setup(t)
+	defer teardown(ctx, server, client)
+
+	var reply int
+	err := client.Call(context.Background(), "Arith.Add", Args{1, 2}, &reply)
+	if err != nil {
+		t.Fatal("Error on call", err)
+	}
+	client.Call(context.Background(), "Arith.Div", Args{1, 0}, &reply)
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("Expected client and server spans of 2 calls, got %d", len(spans))
+	}
+	// The server span ends before the client span
+	serverSpan, clientSpan := spans[0], spans[1]
+	if serverSpan.SpanKind() != trace.SpanKindServer || clientSpan.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("Unexpected span kinds %s and %s", serverSpan.SpanKind(), clientSpan.SpanKind())
+	}
+	if serverSpan.Name() != "Arith.Add" || serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() {
+		t.Fatalf("Expected server span %s child of the client span", serverSpan.Name())
+	}
+	for _, span := range spans[2:] {
+		if span.Status().Code != codes.Error {
+			t.Fatalf("Expected error status on %s span, got %v", span.SpanKind(), span.Status())
+		}
+	}
+}
+
+func TestCallWithoutServer(t *testing.T) {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	defer ctx.Destroy()
+	client, err := NewClient(ctx, "tcp://127.0.0.1:9", zmq.JSONCodec)
+	if err != nil {
+		t.Fatal("Error on client creation", err)
+	}
+
+	// More calls than the high water mark of the dealer accepts
+	var wg sync.WaitGroup
+	for i := 0; i < 1500; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			var reply int
+			err := client.Call(callCtx, "Arith.Add", Args{1, 2}, &reply)
+			if err != context.DeadlineExceeded {
+				t.Errorf("Expected deadline exceeded, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal("Error on close", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked by the unsent requests")
+	}
+}
@@ -0,0 +1,270 @@
+// Package rpc provides remote calls of Go methods, in the manner of net/rpc,
+// over a router socket on the server side and a dealer socket on the
+// client side.
+//
+// A request is the multipart message
+// [request id, "Service.Method", deadline, content type, arguments]
+// and its reply is [request id, "OK", content type, reply] on success or
+// [request id, "ERR", code, message] on failure. Both are preceded by an
+// empty delimiter on the wire.
+//
+// Calls are traced with the global OpenTelemetry tracer provider. The trace
+// context of the client span is appended to requests in a header frame of
+// the tracing package, and is the parent of the server span.
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"github.com/bonnefoa/go-zeromq/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reply statuses
+const (
+	statusOK    = "OK"
+	statusError = "ERR"
+)
+
+// Delay between two checks of the closing of the server or client
+const pollInterval = 100 * time.Millisecond
+
+var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+
+// A method callable remotely
+type methodType struct {
+	method    reflect.Method
+	argType   reflect.Type
+	replyType reflect.Type
+}
+
+type service struct {
+	rcvr    reflect.Value
+	methods map[string]*methodType
+}
+
+// Server calls registered methods on requests received on a router socket.
+// Requests are processed one at a time in the server goroutine.
+type Server struct {
+	socket   *zmq.Socket
+	services map[string]*service
+	err      error
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewServer creates a server bound to the endpoint
+func NewServer(ctx *zmq.Context, endpoint string) (*Server, error) {
+	soc, err := ctx.NewSocket(zmq.Router)
+	if err != nil {
+		return nil, err
+	}
+	err = soc.Bind(endpoint)
+	if err != nil {
+		soc.Close()
+		return nil, err
+	}
+	return &Server{socket: soc, services: map[string]*service{}}, nil
+}
+
+// Register publishes the exported methods of the receiver under the name
+// of its concrete type. Methods must have the form
+//
+//	func (t *T) MethodName(args A, reply *R) error
+//
+// Services must be registered before Start.
+func (s *Server) Register(rcvr interface{}) error {
+	name := reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name()
+	return s.RegisterName(name, rcvr)
+}
+
+// RegisterName is like Register but uses the given name for the service
+func (s *Server) RegisterName(name string, rcvr interface{}) error {
+	if name == "" {
+		return errors.New("rpc: no service name for type " + reflect.TypeOf(rcvr).String())
+	}
+	if _, ok := s.services[name]; ok {
+		return errors.New("rpc: service already defined: " + name)
+	}
+	svc := &service{rcvr: reflect.ValueOf(rcvr), methods: map[string]*methodType{}}
+	rcvrType := reflect.TypeOf(rcvr)
+	for i := 0; i < rcvrType.NumMethod(); i++ {
+		method := rcvrType.Method(i)
+		mtype := method.Type
+		if method.PkgPath != "" || mtype.NumIn() != 3 || mtype.NumOut() != 1 {
+			continue
+		}
+		if mtype.In(2).Kind() != reflect.Ptr || mtype.Out(0) != typeOfError {
+			continue
+		}
+		svc.methods[method.Name] = &methodType{
+			method:    method,
+			argType:   mtype.In(1),
+			replyType: mtype.In(2),
+		}
+	}
+	if len(svc.methods) == 0 {
+		return errors.New("rpc: type " + rcvrType.String() + " has no suitable methods")
+	}
+	s.services[name] = svc
+	return nil
+}
+
+// Start serving requests in a new goroutine
+func (s *Server) Start() {
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run()
+}
+
+// Close stops the server and closes its socket.
+// The error which interrupted the server, if any, is returned.
+func (s *Server) Close() error {
+	if s.done != nil {
+		close(s.done)
+		<-s.stopped
+	}
+	s.socket.SetOptionInt(zmq.Linger, 0)
+	err := s.socket.Close()
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+func (s *Server) run() {
+	defer close(s.stopped)
+	items := zmq.PollItems{&zmq.PollItem{Socket: s.socket, Events: zmq.Pollin}}
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		_, err := items.Poll(pollInterval)
+		if err != nil {
+			s.err = err
+			return
+		}
+		if items[0].REvents&zmq.Pollin == 0 {
+			continue
+		}
+		envelope, body, err := s.socket.RecvFrom(0)
+		if err != nil {
+			s.err = err
+			return
+		}
+		ctx, body := tracing.Extract(context.Background(), body)
+		if len(body) == 0 {
+			continue
+		}
+		reply := append([][]byte{body[0]}, s.serve(ctx, body)...)
+		err = s.socket.SendEnvelope(envelope, reply...)
+		if err != nil {
+			var unreachable *zmq.HostUnreachableError
+			if errors.As(err, &unreachable) {
+				// Client left before the reply
+				continue
+			}
+			s.err = err
+			return
+		}
+	}
+}
+
+func errorReply(code, message string) [][]byte {
+	return [][]byte{[]byte(statusError), []byte(code), []byte(message)}
+}
+
+// serve handles a request in a server span
+func (s *Server) serve(ctx context.Context, body [][]byte) [][]byte {
+	method := ""
+	if len(body) > 1 {
+		method = string(body[1])
+	}
+	_, span := startSpan(ctx, method, trace.SpanKindServer)
+	reply := s.handle(body)
+	var err error
+	if string(reply[0]) == statusError {
+		err = &Error{Code: string(reply[1]), Message: string(reply[2])}
+	}
+	endSpan(span, err)
+	return reply
+}
+
+// Decode and execute a request, returning the reply frames after the id
+func (s *Server) handle(body [][]byte) [][]byte {
+	if len(body) != 5 || len(body[2]) != 8 {
+		return errorReply(CodeBadRequest, "malformed request")
+	}
+	deadline := int64(binary.BigEndian.Uint64(body[2]))
+	if deadline != 0 && time.Now().UnixNano() > deadline {
+		return errorReply(CodeDeadlineExceeded, "deadline exceeded before processing")
+	}
+	name := string(body[1])
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return errorReply(CodeUnknownMethod, "malformed method name "+name)
+	}
+	svc, ok := s.services[name[:dot]]
+	if !ok {
+		return errorReply(CodeUnknownMethod, "unknown service "+name[:dot])
+	}
+	mtype, ok := svc.methods[name[dot+1:]]
+	if !ok {
+		return errorReply(CodeUnknownMethod, "unknown method "+name)
+	}
+	codec, ok := zmq.CodecByContentType(string(body[3]))
+	if !ok {
+		return errorReply(CodeBadRequest, "unknown content type "+string(body[3]))
+	}
+
+	var argv reflect.Value
+	if mtype.argType.Kind() == reflect.Ptr {
+		argv = reflect.New(mtype.argType.Elem())
+	} else {
+		argv = reflect.New(mtype.argType)
+	}
+	err := codec.Unmarshal(body[4], argv.Interface())
+	if err != nil {
+		return errorReply(CodeBadRequest, err.Error())
+	}
+	if mtype.argType.Kind() != reflect.Ptr {
+		argv = argv.Elem()
+	}
+	replyv := reflect.New(mtype.replyType.Elem())
+
+	err = s.call(svc, mtype, argv, replyv)
+	if err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			return errorReply(rpcErr.Code, rpcErr.Message)
+		}
+		return errorReply(CodeApplication, err.Error())
+	}
+	payload, err := codec.Marshal(replyv.Interface())
+	if err != nil {
+		return errorReply(CodeInternal, err.Error())
+	}
+	return [][]byte{[]byte(statusOK), body[3], payload}
+}
+
+// Call the method, a panic is turned into an internal error
+func (s *Server) call(svc *service, mtype *methodType, argv, replyv reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{Code: CodeInternal, Message: fmt.Sprint(r)}
+		}
+	}()
+	out := mtype.method.Func.Call([]reflect.Value{svc.rcvr, argv, replyv})
+	if errv := out[0].Interface(); errv != nil {
+		return errv.(error)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer of calls, obtained from the global tracer provider
+const tracerName = "github.com/bonnefoa/go-zeromq/rpc"
+
+// startSpan starts the span of a call, named after its method
+func startSpan(ctx context.Context, method string, kind trace.SpanKind) (context.Context, trace.Span) {
+	service, name := "", method
+	if dot := strings.LastIndex(method, "."); dot >= 0 {
+		service, name = method[:dot], method[dot+1:]
+	}
+	return otel.Tracer(tracerName).Start(ctx, method,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "zmq"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", name),
+		))
+}
+
+// endSpan records the error of a call and ends its span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		var rpcErr *Error
+		if errors.As(err, &rpcErr) {
+			span.SetAttributes(attribute.String("rpc.zmq.error_code", rpcErr.Code))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,108 @@
+package zmq
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShutdownError is returned by Shutdown when the context is not
+// terminated before the deadline
+type ShutdownError struct {
+	// Sockets describes the sockets closed by Shutdown whose linger is
+	// not over, with their type and endpoints
+	Sockets []string
+	Err     error
+}
+
+func (e *ShutdownError) Error() string {
+	if len(e.Sockets) == 0 {
+		return fmt.Sprintf("zmq: context not terminated: %v", e.Err)
+	}
+	return fmt.Sprintf("zmq: context not terminated: %v, lingering sockets: %s", e.Err, strings.Join(e.Sockets, ", "))
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// termination is the state of the first Shutdown of a context, the next
+// calls wait for the same termination
+type termination struct {
+	once sync.Once
+	// err is the error of the context shutdown
+	err error
+	// Sockets closed by Shutdown with their descriptions
+	sockets      []*Socket
+	descriptions []string
+	// done is closed when the context is destroyed
+	done       chan struct{}
+	destroyErr error
+}
+
+// destroyed reports whether the context was destroyed by Shutdown
+func (ctx *Context) destroyed() bool {
+	select {
+	case <-ctx.shutdownState.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown terminates the context without waiting for the sockets to be
+// closed by their users. Blocking calls of the sockets return
+// ErrTerminated, once they have returned the sockets are closed with a
+// linger of half the time left before the deadline of c and the context
+// is terminated. The sockets must not be used once their pending calls
+// have returned, and Destroy must not be called.
+//
+// A ShutdownError is returned with the sockets still lingering when c is
+// done before the context is terminated. The termination goes on in the
+// background, calling Shutdown again waits for its end.
+func (ctx *Context) Shutdown(c context.Context) error {
+	st := &ctx.shutdownState
+	st.once.Do(func() {
+		st.err = ctx.shutdown()
+		if st.err != nil {
+			return
+		}
+		linger := -1
+		if deadline, ok := c.Deadline(); ok {
+			// Half of the time left is kept to terminate the context
+			linger = int(max(time.Until(deadline)/2, 0) / time.Millisecond)
+		}
+		st.sockets = ctx.openSockets()
+		for _, s := range st.sockets {
+			st.descriptions = append(st.descriptions, fmt.Sprintf("%s %v", s.socketType, s.endpointList()))
+			s.interrupt()
+			if linger >= 0 {
+				s.SetOptionInt(Linger, linger)
+			}
+			s.terminate()
+		}
+		go func() {
+			st.destroyErr = ctx.Destroy()
+			close(st.done)
+		}()
+	})
+	if st.err != nil {
+		return st.err
+	}
+	select {
+	case <-st.done:
+		return st.destroyErr
+	case <-c.Done():
+	}
+	var lingering []string
+	for i, s := range st.sockets {
+		if s.lingering() {
+			lingering = append(lingering, st.descriptions[i])
+		}
+	}
+	sort.Strings(lingering)
+	return &ShutdownError{Sockets: lingering, Err: c.Err()}
+}
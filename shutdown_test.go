@@ -0,0 +1,128 @@
+package zmq
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	pull, _ := ctx.NewSocket(Pull)
+	pull.Bind(InprocEndpoint)
+	push, _ := ctx.NewSocket(Push)
+	push.Connect("tcp://127.0.0.1:9")
+	push.SetOptionInt(Linger, -1)
+	push.Send([]byte("pending"), 0)
+
+	received := make(chan error, 1)
+	go func() {
+		_, err := pull.Recv(0)
+		received <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	c, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	err = ctx.Shutdown(c)
+	if err != nil {
+		t.Fatal("Error on shutdown", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected shutdown before the deadline, took %s", elapsed)
+	}
+	select {
+	case err := <-received:
+		if err != ErrTerminated {
+			t.Fatalf("Expected ErrTerminated on pending receive, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pending receive not interrupted")
+	}
+	if err := pull.Close(); err != syscall.ENOTSOCK {
+		t.Fatalf("Expected closed socket, got %v", err)
+	}
+	if _, err := ctx.NewSocket(Pull); err != ErrTerminated {
+		t.Fatalf("Expected ErrTerminated on new socket, got %v", err)
+	}
+}
+
+func TestShutdownDeadlineExceeded(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := "tcp://" + l.Addr().String()
+	l.Close()
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	push, _ := ctx.NewSocket(Push)
+	push.SetOptionInt(Linger, -1)
+	push.Connect(endpoint)
+	push.SendCopy([]byte("pending"), 0)
+
+	// Without deadline the linger of the socket is kept, the pending
+	// message can not be sent
+	c, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	err = ctx.Shutdown(c)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected ShutdownError, got %v", err)
+	}
+	expected := []string{"PUSH [" + endpoint + "]"}
+	if !reflect.DeepEqual(shutdownErr.Sockets, expected) {
+		t.Fatalf("Expected lingering sockets %q, got %q", expected, shutdownErr.Sockets)
+	}
+
+	// The termination ends once the message is sent to a new peer
+	peerCtx, err := NewContext()
+	if err != nil {
+		t.Fatal("Error on context creation", err)
+	}
+	defer peerCtx.Destroy()
+	pull, _ := peerCtx.NewSocket(Pull)
+	defer pull.Close()
+	err = pull.Bind(endpoint)
+	if err != nil {
+		t.Fatal("Error on bind", err)
+	}
+	msg, err := pull.Recv(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	if string(msg.Data) != "pending" {
+		t.Fatalf("Expected pending message, got %q", msg.Data)
+	}
+	msg.Close()
+	c, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = ctx.Shutdown(c)
+	if err != nil {
+		t.Fatal("Error on second shutdown", err)
+	}
+}
+
+func TestShutdownError(t *testing.T) {
+	err := error(&ShutdownError{
+		Sockets: []string{"PUSH [tcp://127.0.0.1:5555]"},
+		Err:     context.DeadlineExceeded,
+	})
+	expected := "zmq: context not terminated: context deadline exceeded, lingering sockets: PUSH [tcp://127.0.0.1:5555]"
+	if err.Error() != expected {
+		t.Fatalf("Expected %q, got %q", expected, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected the error of the context")
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !purego
+
 package zmq
 
 /*
@@ -9,12 +11,27 @@ import "C"
 
 import (
 	"reflect"
+	"sync"
+	"time"
 	"unsafe"
 )
 
 // Socket represents a zero mq socket
 type Socket struct {
-	psocket unsafe.Pointer
+	psocket    unsafe.Pointer
+	ctx        *Context
+	socketType SocketType
+	// Bound and connected endpoints, reported by the logs
+	endpoints []string
+	// Active subscriptions with their subscription count
+	subscriptions map[string]int
+	// calls is held for reading by Send and Recv, and for writing by
+	// Shutdown which does not close the socket during a call
+	calls      sync.RWMutex
+	terminated bool
+	// lingerEnd is set when Shutdown closes the socket, it is zero when
+	// the socket lingers until its messages are sent
+	lingerEnd time.Time
 }
 
 // SocketType identifies the type of the socket
@@ -46,21 +63,75 @@ const (
 
 // Close 0mq socket.
 func (s *Socket) Close() error {
+	// Sockets are closed once, they may be closed by Shutdown
+	if err := s.ctx.unregister(s); err != nil {
+		s.logEvent("close failed", "", err)
+		return err
+	}
 	rc, err := C.zmq_close(s.psocket)
 	if rc == 0 {
+		s.logEvent("socket closed", "", nil)
 		return nil
 	}
+	s.logEvent("close failed", "", err)
 	return err
 }
 
+// interrupt waits for the calls in progress, which return ErrTerminated
+// once the context is shut down, and makes the next calls return
+// ErrTerminated without using the zmq socket
+func (s *Socket) interrupt() {
+	s.calls.Lock()
+	s.terminated = true
+	s.calls.Unlock()
+}
+
+// terminate closes the socket for Shutdown and records the end of its
+// linger
+func (s *Socket) terminate() error {
+	linger, err := s.GetOptionInt(Linger)
+	if err == nil && linger >= 0 {
+		s.lingerEnd = time.Now().Add(time.Duration(linger) * time.Millisecond)
+	}
+	return s.Close()
+}
+
+// lingering reports whether the linger of a socket closed by Shutdown
+// may not be over. libzmq does not tell when a closed socket has sent its
+// messages, its linger period is an upper bound.
+func (s *Socket) lingering() bool {
+	return s.lingerEnd.IsZero() || time.Now().Before(s.lingerEnd)
+}
+
+// enter starts a call using the zmq socket, which must be ended by leave
+func (s *Socket) enter() error {
+	s.calls.RLock()
+	if s.terminated {
+		s.calls.RUnlock()
+		return ErrTerminated
+	}
+	return nil
+}
+
+func (s *Socket) leave() {
+	s.calls.RUnlock()
+}
+
 // Bind the socket to the given address
 func (s *Socket) Bind(address string) error {
+	if err := checkTransport(address); err != nil {
+		s.logEvent("bind failed", address, err)
+		return err
+	}
 	addr := C.CString(address)
 	defer C.free(unsafe.Pointer(addr))
 	rc, err := C.zmq_bind(s.psocket, addr)
 	if rc == 0 {
+		s.endpoints = append(s.endpoints, address)
+		s.logEvent("socket bound", address, nil)
 		return nil
 	}
+	s.logEvent("bind failed", address, err)
 	return err
 }
 
@@ -70,19 +141,29 @@ func (s *Socket) Unbind(address string) error {
 	defer C.free(unsafe.Pointer(addr))
 	rc, err := C.zmq_unbind(s.psocket, addr)
 	if rc == 0 {
+		s.removeEndpoint(address)
+		s.logEvent("socket unbound", address, nil)
 		return nil
 	}
+	s.logEvent("unbind failed", address, err)
 	return err
 }
 
 // Connect the socket to the given address
 func (s *Socket) Connect(address string) error {
+	if err := checkTransport(address); err != nil {
+		s.logEvent("connect failed", address, err)
+		return err
+	}
 	addr := C.CString(address)
 	defer C.free(unsafe.Pointer(addr))
 	rc, err := C.zmq_connect(s.psocket, addr)
 	if rc == 0 {
+		s.endpoints = append(s.endpoints, address)
+		s.logEvent("socket connected", address, nil)
 		return nil
 	}
+	s.logEvent("connect failed", address, err)
 	return err
 }
 
@@ -92,13 +173,36 @@ func (s *Socket) Disconnect(address string) error {
 	defer C.free(unsafe.Pointer(addr))
 	rc, err := C.zmq_disconnect(s.psocket, addr)
 	if rc == 0 {
+		s.removeEndpoint(address)
+		s.logEvent("socket disconnected", address, nil)
 		return nil
 	}
+	s.logEvent("disconnect failed", address, err)
 	return err
 }
 
+// removeEndpoint forgets an unbound or disconnected endpoint
+func (s *Socket) removeEndpoint(address string) {
+	for i, endpoint := range s.endpoints {
+		if endpoint == address {
+			s.endpoints = append(s.endpoints[:i], s.endpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// endpointList returns the bound and connected endpoints
+func (s *Socket) endpointList() []string {
+	return append([]string{}, s.endpoints...)
+}
+
 // Send data to the socket
 func (s *Socket) Send(data []byte, flag SendFlag) error {
+	if err := s.enter(); err != nil {
+		s.logEvent("send failed", "", err)
+		return err
+	}
+	defer s.leave()
 	var pdata unsafe.Pointer
 	var msg C.zmq_msg_t
 	if len(data) == 0 {
@@ -121,6 +225,7 @@ func (s *Socket) Send(data []byte, flag SendFlag) error {
 			continue
 		}
 		if rc == -1 {
+			s.logEvent("send failed", "", err)
 			return err
 		}
 		break
@@ -129,48 +234,41 @@ func (s *Socket) Send(data []byte, flag SendFlag) error {
 	return nil
 }
 
-// SendMultipart sends a message with on or several frames to the socket
-func (s *Socket) SendMultipart(data [][]byte, flag SendFlag) error {
-	moreFlag := flag | SndMore
-	for _, v := range data[:len(data)-1] {
-		err := s.Send(v, moreFlag)
-		if err != nil {
-			return err
-		}
-	}
-	err := s.Send(data[len(data)-1], flag)
-	if err != nil {
+// SendCopy sends data copied by the zmq library, unlike Send the slice
+// can be modified or collected as soon as SendCopy returns
+func (s *Socket) SendCopy(data []byte, flag SendFlag) error {
+	if err := s.enter(); err != nil {
+		s.logEvent("send failed", "", err)
 		return err
 	}
-	return nil
-}
-
-// RecvMultipart receives a multi part message from the socket
-func (s *Socket) RecvMultipart(flag SendFlag) (*MessageMultipart, error) {
-	msg := &MessageMultipart{}
-	msg.parts = make([]*MessagePart, 0, 10)
-	i := 0
+	defer s.leave()
+	var pdata unsafe.Pointer
+	if len(data) > 0 {
+		pdata = unsafe.Pointer(&data[0])
+	}
 	for {
-		msgPart, err := s.Recv(flag)
-		if err != nil {
-			return nil, err
+		rc, err := C.zmq_send(s.psocket, pdata, C.size_t(len(data)), C.int(flag))
+		// Retry send on an interrupted system call
+		if rc == -1 && C.zmq_errno() == C.int(C.EINTR) {
+			continue
 		}
-		msg.parts = append(msg.parts, msgPart)
-		i += 1
-		if !msgPart.HasMore() {
-			break
+		if rc == -1 {
+			s.logEvent("send failed", "", err)
+			return err
 		}
+		return nil
 	}
-	// Make slice iterable
-	msg.parts = msg.parts[:i]
-	msg.aggregateData()
-	return msg, nil
 }
 
 // Recv receives a message part from the socket
 // It is necessary to call CloseMsg on each MessagePart to avoid memory leak
 // when the data is not needed anymore
 func (s *Socket) Recv(flag SendFlag) (*MessagePart, error) {
+	if err := s.enter(); err != nil {
+		s.logEvent("receive failed", "", err)
+		return nil, err
+	}
+	defer s.leave()
 	var msg C.zmq_msg_t
 	rc, err := C.zmq_msg_init(&msg)
 	if rc != 0 {
@@ -184,6 +282,7 @@ func (s *Socket) Recv(flag SendFlag) (*MessagePart, error) {
 		}
 		if rc == -1 {
 			C.zmq_msg_close(&msg)
+			s.logEvent("receive failed", "", err)
 			return nil, err
 		}
 		break
@@ -245,6 +344,12 @@ const (
 	Unsubscribe     = SocketOptionString(C.ZMQ_UNSUBSCRIBE)
 	RouterMandatory = SocketOptionInt(C.ZMQ_ROUTER_MANDATORY)
 	XpubVerbose     = SocketOptionInt(C.ZMQ_XPUB_VERBOSE)
+	XpubVerboser    = SocketOptionInt(C.ZMQ_XPUB_VERBOSER)
+	XpubManual      = SocketOptionInt(C.ZMQ_XPUB_MANUAL)
+	XpubWelcomeMsg  = SocketOptionString(C.ZMQ_XPUB_WELCOME_MSG)
+	PlainServer     = SocketOptionInt(C.ZMQ_PLAIN_SERVER)
+	PlainUsername   = SocketOptionString(C.ZMQ_PLAIN_USERNAME)
+	PlainPassword   = SocketOptionString(C.ZMQ_PLAIN_PASSWORD)
 )
 
 func (s *Socket) getOption(option C.int, v interface{}, size *C.size_t) error {
@@ -334,6 +439,31 @@ func (s *Socket) SetOptionString(option SocketOptionString, value *string) error
 	return err
 }
 
+// GetOptionBytes gets the value of a socket option as a byte slice
+func (s *Socket) GetOptionBytes(option SocketOptionString) ([]byte, error) {
+	var value [1024]byte
+	size := C.size_t(unsafe.Sizeof(value))
+	err := s.getOption(C.int(option), &value, &size)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), value[:size]...), nil
+}
+
+// SetOptionBytes sets a binary socket option to the given value
+func (s *Socket) SetOptionBytes(option SocketOptionString, value []byte) error {
+	if len(value) == 0 {
+		// Empty values still need a valid pointer
+		var empty C.char
+		return s.setOption(C.int(option), &empty, 0)
+	}
+	size := C.size_t(len(value))
+	cbytes := C.CBytes(value)
+	err := s.setOption(C.int(option), cbytes, size)
+	C.free(cbytes)
+	return err
+}
+
 // SocketEvent identifies socket events available
 type SocketEvent C.int
 
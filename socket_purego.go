@@ -0,0 +1,650 @@
+//go:build purego
+
+package zmq
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Socket represents a zero mq socket
+type Socket struct {
+	ctx        *Context
+	socketType SocketType
+	// Active subscriptions with their subscription count
+	subscriptions map[string]int
+
+	// Fields shared with the connection goroutines, guarded by mu
+	mu           sync.Mutex
+	options      map[int]int64
+	strOptions   map[int][]byte
+	peers        []*peer
+	next         int
+	endpoints    map[string]*endpoint
+	lastEndpoint string
+	filter       map[string]int
+	peerSubs     map[string]int
+	routingID    uint32
+	monitor      *monitor
+	closed       bool
+
+	// incoming receives the messages read from all peers
+	incoming chan inMsg
+	// changed is signaled when peers are attached, detached or drained
+	changed chan struct{}
+	done    chan struct{}
+	// running counts the connection goroutines, a closed socket stays in
+	// its context until they end
+	running sync.WaitGroup
+
+	// State of the goroutine using the socket
+	sendBuf     [][]byte
+	recvBuf     [][]byte
+	reqPeer     *peer
+	reqWaiting  bool
+	repPeer     *peer
+	repEnvelope [][]byte
+	repWaiting  bool
+	lastSubPeer *peer
+}
+
+// SocketType identifies the type of the socket
+type SocketType int
+
+// Bindings to available socket types
+const (
+	Pair   = SocketType(0)
+	Pub    = SocketType(1)
+	Sub    = SocketType(2)
+	Req    = SocketType(3)
+	Rep    = SocketType(4)
+	Dealer = SocketType(5)
+	Router = SocketType(6)
+	Pull   = SocketType(7)
+	Push   = SocketType(8)
+	Xpub   = SocketType(9)
+	Xsub   = SocketType(10)
+)
+
+// SendFlag identifies the flags passed to zeromq send command
+type SendFlag int
+
+// Bindings to available send flags
+const (
+	SndMore  = SendFlag(2)
+	DontWait = SendFlag(1)
+)
+
+// Close 0mq socket.
+func (s *Socket) Close() error {
+	select {
+	case <-s.done:
+		s.logEvent("close failed", "", syscall.ENOTSOCK)
+		return syscall.ENOTSOCK
+	default:
+	}
+	// Logged before the endpoints are released
+	s.logEvent("socket closed", "", nil)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return syscall.ENOTSOCK
+	}
+	s.closed = true
+	close(s.done)
+	endpoints := s.endpoints
+	peers := s.peers
+	m := s.monitor
+	s.endpoints = nil
+	s.peers = nil
+	s.monitor = nil
+	linger := s.lingerDuration()
+	s.mu.Unlock()
+
+	for _, e := range endpoints {
+		e.close()
+		if e.listener != nil {
+			m.event(EventClosed, 0, e.address)
+		}
+	}
+	for _, p := range peers {
+		p.close(linger)
+	}
+	m.close()
+	go func() {
+		s.running.Wait()
+		s.ctx.unregister(s)
+	}()
+	return nil
+}
+
+// interrupt is a no-op, calls in progress return ErrTerminated on their
+// own and the socket can be closed concurrently
+func (s *Socket) interrupt() {}
+
+// terminate closes the socket for Shutdown
+func (s *Socket) terminate() error {
+	return s.Close()
+}
+
+// lingering reports whether a closed socket still sends its queued
+// messages
+func (s *Socket) lingering() bool {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	_, ok := s.ctx.sockets[s]
+	return ok
+}
+
+// Bind the socket to the given address
+func (s *Socket) Bind(address string) error {
+	err := s.bind(address)
+	if err != nil {
+		s.logEvent("bind failed", address, err)
+		return err
+	}
+	s.logEvent("socket bound", address, nil)
+	return nil
+}
+
+func (s *Socket) bind(address string) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if err := checkTransport(address); err != nil {
+		return err
+	}
+	transport, addr, err := splitAddress(address)
+	if err != nil {
+		return err
+	}
+	var l net.Listener
+	switch transport {
+	case "tcp":
+		network := "tcp"
+		if s.option(int(Ipv4only)) != 0 {
+			network = "tcp4"
+		}
+		l, err = net.Listen(network, tcpBindAddress(addr))
+	case "ipc":
+		os.Remove(addr)
+		l, err = net.Listen("unix", addr)
+	case "inproc":
+		l, err = s.ctx.listenInproc(addr)
+	default:
+		return syscall.EPROTONOSUPPORT
+	}
+	if err != nil {
+		errno := errnoOf(err)
+		s.event(EventBindFailed, int(errno), address)
+		return errno
+	}
+
+	resolved := address
+	if transport == "tcp" {
+		resolved = "tcp://" + tcpEndpoint(l.Addr())
+	}
+	e := &endpoint{address: resolved, listener: l}
+	s.mu.Lock()
+	if s.endpoints == nil {
+		s.endpoints = map[string]*endpoint{}
+	}
+	s.endpoints[resolved] = e
+	s.lastEndpoint = resolved
+	s.mu.Unlock()
+
+	go s.acceptLoop(e)
+	s.event(EventListening, 0, resolved)
+	return nil
+}
+
+// Unbind the socket from the given address
+func (s *Socket) Unbind(address string) error {
+	err := s.unbind(address)
+	if err != nil {
+		s.logEvent("unbind failed", address, err)
+		return err
+	}
+	s.logEvent("socket unbound", address, nil)
+	return nil
+}
+
+func (s *Socket) unbind(address string) error {
+	return s.removeEndpoint(address, true)
+}
+
+// Connect the socket to the given address
+func (s *Socket) Connect(address string) error {
+	err := s.connect(address)
+	if err != nil {
+		s.logEvent("connect failed", address, err)
+		return err
+	}
+	s.logEvent("socket connected", address, nil)
+	return nil
+}
+
+func (s *Socket) connect(address string) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if err := checkTransport(address); err != nil {
+		return err
+	}
+	transport, addr, err := splitAddress(address)
+	if err != nil {
+		return err
+	}
+	switch transport {
+	case "tcp":
+		if i := strings.LastIndex(addr, ";"); i >= 0 {
+			addr = addr[i+1:]
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return syscall.EINVAL
+		}
+	case "ipc", "inproc":
+	default:
+		return syscall.EPROTONOSUPPORT
+	}
+
+	e := &endpoint{address: address, stop: make(chan struct{})}
+	p := s.newPeer(address, true)
+	s.mu.Lock()
+	if s.endpoints == nil {
+		s.endpoints = map[string]*endpoint{}
+	}
+	s.endpoints[address] = e
+	s.lastEndpoint = address
+	if s.options[int(DelayAttachOnConnect)] == 0 {
+		s.peers = append(s.peers, p)
+	}
+	s.mu.Unlock()
+
+	// Inproc connections to a bound endpoint are established synchronously
+	// like libzmq does
+	var c *connection
+	if transport == "inproc" {
+		c = s.dial(e, transport, addr, p)
+	}
+	s.running.Add(1)
+	go s.dialLoop(e, transport, addr, p, c)
+	return nil
+}
+
+// Disconnect the socket from the given address
+func (s *Socket) Disconnect(address string) error {
+	err := s.disconnect(address)
+	if err != nil {
+		s.logEvent("disconnect failed", address, err)
+		return err
+	}
+	s.logEvent("socket disconnected", address, nil)
+	return nil
+}
+
+func (s *Socket) disconnect(address string) error {
+	return s.removeEndpoint(address, false)
+}
+
+// Send data to the socket
+func (s *Socket) Send(data []byte, flag SendFlag) error {
+	err := s.send(data, flag)
+	if err != nil {
+		s.logEvent("send failed", "", err)
+	}
+	return err
+}
+
+func (s *Socket) send(data []byte, flag SendFlag) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if len(s.sendBuf) == 0 {
+		if err := s.checkSend(); err != nil {
+			return err
+		}
+	}
+	// Data is queued to the connection goroutines, it has to be copied
+	s.sendBuf = append(s.sendBuf, append([]byte{}, data...))
+	if flag&SndMore != 0 {
+		return nil
+	}
+	frames := s.sendBuf
+	s.sendBuf = nil
+	return s.route(frames, flag&DontWait != 0)
+}
+
+// SendCopy sends data like Send, which always copies the data with the
+// pure Go backend
+func (s *Socket) SendCopy(data []byte, flag SendFlag) error {
+	return s.Send(data, flag)
+}
+
+// Recv receives a message part from the socket
+// It is necessary to call CloseMsg on each MessagePart to avoid memory leak
+// when the data is not needed anymore
+func (s *Socket) Recv(flag SendFlag) (*MessagePart, error) {
+	msgPart, err := s.recv(flag)
+	if err != nil {
+		s.logEvent("receive failed", "", err)
+	}
+	return msgPart, err
+}
+
+func (s *Socket) recv(flag SendFlag) (*MessagePart, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if len(s.recvBuf) == 0 {
+		if err := s.checkRecv(); err != nil {
+			return nil, err
+		}
+		timeout := s.option(int(Rcvtimeo))
+		block := flag&DontWait == 0 && timeout != 0
+		if err := s.fill(block, time.Duration(timeout)*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+	data := s.recvBuf[0]
+	s.recvBuf = s.recvBuf[1:]
+	msgPart := &MessagePart{
+		Data:   data,
+		zmqMsg: &zmqMsg{data: data, more: len(s.recvBuf) > 0},
+	}
+	return msgPart, nil
+}
+
+// endpointList returns the bound and connected endpoints sorted
+func (s *Socket) endpointList() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := make([]string, 0, len(s.endpoints))
+	for address := range s.endpoints {
+		endpoints = append(endpoints, address)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// SocketOptionInt identifies socket option which returns int value
+type SocketOptionInt int
+
+// SocketOptionUint64 identifies socket option which returns uint64 value
+type SocketOptionUint64 int
+
+// SocketOptionInt64 identifies socket option which returns int64 value
+type SocketOptionInt64 int
+
+// SocketOptionString identifies socket option which returns string value
+type SocketOptionString int
+
+// Bindings to socket options
+const (
+	Type                 = SocketOptionInt(16)
+	Rcvmore              = SocketOptionInt(13)
+	Sndhwm               = SocketOptionInt(23)
+	Rcvhwm               = SocketOptionInt(24)
+	Affinity             = SocketOptionUint64(4)
+	Identity             = SocketOptionString(5)
+	Rate                 = SocketOptionInt(8)
+	RecoveryIvl          = SocketOptionInt(9)
+	Sndbuf               = SocketOptionInt(11)
+	Rcvbuf               = SocketOptionInt(12)
+	Linger               = SocketOptionInt(17)
+	ReconnectIvl         = SocketOptionInt(18)
+	ReconnectIvlMax      = SocketOptionInt(21)
+	Backlog              = SocketOptionInt(19)
+	Maxmsgsize           = SocketOptionInt64(22)
+	MulticastHops        = SocketOptionInt(25)
+	Rcvtimeo             = SocketOptionInt(27)
+	Sndtimeo             = SocketOptionInt(28)
+	Ipv4only             = SocketOptionInt(31)
+	DelayAttachOnConnect = SocketOptionInt(39)
+	Fd                   = SocketOptionInt(14)
+	Events               = SocketOptionInt(15)
+	LastEndpoint         = SocketOptionString(32)
+	TcpKeepalive         = SocketOptionInt(34)
+	TcpKeepaliveIdle     = SocketOptionInt(36)
+	TcpKeepaliveCnt      = SocketOptionInt(35)
+	TcpKeepaliveIntvl    = SocketOptionInt(37)
+
+	Subscribe       = SocketOptionString(6)
+	Unsubscribe     = SocketOptionString(7)
+	RouterMandatory = SocketOptionInt(33)
+	XpubVerbose     = SocketOptionInt(40)
+	XpubVerboser    = SocketOptionInt(78)
+	XpubManual      = SocketOptionInt(71)
+	XpubWelcomeMsg  = SocketOptionString(72)
+	PlainServer     = SocketOptionInt(44)
+	PlainUsername   = SocketOptionString(45)
+	PlainPassword   = SocketOptionString(46)
+)
+
+// Default values of the numeric options, as documented by libzmq
+var defaultOptions = map[int]int64{
+	int(Sndhwm):               1000,
+	int(Rcvhwm):               1000,
+	int(Affinity):             0,
+	int(Rate):                 100,
+	int(RecoveryIvl):          10000,
+	int(Sndbuf):               -1,
+	int(Rcvbuf):               -1,
+	int(Linger):               -1,
+	int(ReconnectIvl):         100,
+	int(ReconnectIvlMax):      0,
+	int(Backlog):              100,
+	int(Maxmsgsize):           -1,
+	int(MulticastHops):        1,
+	int(Rcvtimeo):             -1,
+	int(Sndtimeo):             -1,
+	int(Ipv4only):             1,
+	int(DelayAttachOnConnect): 0,
+	int(TcpKeepalive):         -1,
+	int(TcpKeepaliveIdle):     -1,
+	int(TcpKeepaliveCnt):      -1,
+	int(TcpKeepaliveIntvl):    -1,
+	int(RouterMandatory):      0,
+	int(XpubVerbose):          0,
+	int(XpubVerboser):         0,
+	int(XpubManual):           0,
+	int(PlainServer):          0,
+}
+
+// String options which can be set and read back
+var stringOptions = map[int]bool{
+	int(Identity):       true,
+	int(XpubWelcomeMsg): true,
+	int(PlainUsername):  true,
+	int(PlainPassword):  true,
+}
+
+// option returns the value of a numeric option
+func (s *Socket) option(option int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.options[option]
+}
+
+func (s *Socket) getOption(option int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.options[option]
+	if !ok {
+		return 0, syscall.EINVAL
+	}
+	return value, nil
+}
+
+func (s *Socket) setOption(option int, value int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.options[option]; !ok {
+		return syscall.EINVAL
+	}
+	s.options[option] = value
+	return nil
+}
+
+// GetOptionInt gets the value of a socket option as an int
+func (s *Socket) GetOptionInt(option SocketOptionInt) (int, error) {
+	switch option {
+	case Type:
+		return int(s.socketType), nil
+	case Rcvmore:
+		if len(s.recvBuf) > 0 {
+			return 1, nil
+		}
+		return 0, nil
+	case Events:
+		if len(s.recvBuf) == 0 {
+			s.fill(false, 0)
+		}
+		return int(s.events()), nil
+	case Fd:
+		return -1, syscall.ENOTSUP
+	}
+	value, err := s.getOption(int(option))
+	return int(value), err
+}
+
+// GetOptionUint64 gets the value of a socket option as an uint64
+func (s *Socket) GetOptionUint64(option SocketOptionUint64) (uint64, error) {
+	value, err := s.getOption(int(option))
+	return uint64(value), err
+}
+
+// GetOptionInt64 gets the value of a socket option as an int64
+func (s *Socket) GetOptionInt64(option SocketOptionUint64) (int64, error) {
+	return s.getOption(int(option))
+}
+
+// GetOptionString gets the value of a socket option as a string
+func (s *Socket) GetOptionString(option SocketOptionString) (string, error) {
+	value, err := s.GetOptionBytes(option)
+	return string(value), err
+}
+
+// GetOptionBytes gets the value of a socket option as a byte slice
+func (s *Socket) GetOptionBytes(option SocketOptionString) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if option == LastEndpoint {
+		return []byte(s.lastEndpoint), nil
+	}
+	if !stringOptions[int(option)] {
+		return nil, syscall.EINVAL
+	}
+	return append([]byte{}, s.strOptions[int(option)]...), nil
+}
+
+// SetOptionInt sets a int socket option to the given value
+func (s *Socket) SetOptionInt(option SocketOptionInt, value int) error {
+	return s.setOption(int(option), int64(value))
+}
+
+// SetOptionInt64 sets a int 64 socket option to the given value
+func (s *Socket) SetOptionInt64(option SocketOptionInt64, value int64) error {
+	return s.setOption(int(option), value)
+}
+
+// SetOptionUint64 sets a uint 64 socket option to the given value
+func (s *Socket) SetOptionUint64(option SocketOptionUint64, value uint64) error {
+	return s.setOption(int(option), int64(value))
+}
+
+// SetOptionString sets a string socket option to the given value. Can be nil
+func (s *Socket) SetOptionString(option SocketOptionString, value *string) error {
+	if value == nil {
+		return s.SetOptionBytes(option, nil)
+	}
+	return s.SetOptionBytes(option, []byte(*value))
+}
+
+// SetOptionBytes sets a binary socket option to the given value
+func (s *Socket) SetOptionBytes(option SocketOptionString, value []byte) error {
+	switch option {
+	case Subscribe, Unsubscribe:
+		return s.subscribe(option == Subscribe, string(value))
+	case Identity:
+		if len(value) > 255 || len(value) > 0 && value[0] == 0 {
+			return syscall.EINVAL
+		}
+	}
+	if !stringOptions[int(option)] {
+		return syscall.EINVAL
+	}
+	s.mu.Lock()
+	s.strOptions[int(option)] = append([]byte{}, value...)
+	s.mu.Unlock()
+	return nil
+}
+
+// SocketEvent identifies socket events available
+type SocketEvent int
+
+// Bindings to socket events
+const (
+	EventConnected      = SocketEvent(0x0001)
+	EventConnectDelayed = SocketEvent(0x0002)
+	EventConnectRetried = SocketEvent(0x0004)
+	EventListening      = SocketEvent(0x0008)
+	EventBindFailed     = SocketEvent(0x0010)
+	EventAccepted       = SocketEvent(0x0020)
+	EventAcceptFailed   = SocketEvent(0x0040)
+	EventClosed         = SocketEvent(0x0080)
+	EventCloseFailed    = SocketEvent(0x0100)
+	EventDisconnected   = SocketEvent(0x0200)
+	EventAll            = SocketEvent(0xFFFF)
+)
+
+// Monitor binds event to the socket
+func (s *Socket) Monitor(endpoint string, events SocketEvent) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	var m *monitor
+	if endpoint != "" {
+		if !strings.HasPrefix(endpoint, "inproc://") {
+			return syscall.EPROTONOSUPPORT
+		}
+		var err error
+		m, err = newMonitor(s.ctx, endpoint, events)
+		if err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	previous := s.monitor
+	s.monitor = m
+	s.mu.Unlock()
+	previous.close()
+	return nil
+}
+
+// check returns an error when the socket can not be used anymore
+func (s *Socket) check() error {
+	select {
+	case <-s.done:
+		return syscall.ENOTSOCK
+	case <-s.ctx.term:
+		return ErrTerminated
+	default:
+		return nil
+	}
+}
+
+// errnoOf extracts the system error number from network errors
+func errnoOf(err error) syscall.Errno {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno
+	}
+	return syscall.EINVAL
+}
@@ -140,6 +140,37 @@ func TestSocketSend(t *testing.T) {
 	}
 }
 
+func TestLingerBeforeConnection(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pull, clientType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+	// Messages queued before the connection are sent during linger
+	// after the socket is closed
+	err := env.client.Connect(TcpEndpoint)
+	if err != nil {
+		t.Fatal("Error on client connect", err)
+	}
+	data := []byte("queued")
+	err = env.client.Send(data, 0)
+	if err != nil {
+		t.Fatal("Error on client send", err)
+	}
+	env.client.Close()
+	env.client = nil
+	err = env.server.Bind(TcpEndpoint)
+	if err != nil {
+		t.Fatal("Error on server bind", err)
+	}
+	response, err := env.server.Recv(0)
+	if err != nil {
+		t.Fatal("Error on server receive", err)
+	}
+	if !reflect.DeepEqual(response.Data, data) {
+		t.Fatalf("server received %v != sended data %v", response.Data, data)
+	}
+	env.server.Unbind(TcpEndpoint)
+}
+
 func TestMultipart(t *testing.T) {
 	env := &Env{Tester: t, serverType: Pull, endpoint: TcpEndpoint, clientType: Push}
 	env.setupEnv()
@@ -159,6 +190,27 @@ func TestMultipart(t *testing.T) {
 	}
 }
 
+func TestSocketSendCopy(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pull, endpoint: TcpEndpoint, clientType: Push}
+	env.setupEnv()
+	defer env.destroyEnv()
+	data := []byte("first")
+	err := env.client.SendCopy(data, 0)
+	if err != nil {
+		t.Fatal("Error on copy send", err)
+	}
+	// The sent message does not change with the slice
+	copy(data, "reuse")
+	res, err := env.server.Recv(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	defer res.Close()
+	if string(res.Data) != "first" {
+		t.Fatalf("Expected 'first', got %q", res.Data)
+	}
+}
+
 func TestSendBigMessage(t *testing.T) {
 	env := &Env{Tester: t, serverType: Pull, endpoint: TcpEndpoint, clientType: Push}
 	env.setupEnv()
@@ -295,22 +347,23 @@ func TestSocketMonitor(t *testing.T) {
 	monitorSoc.Connect(monitorEndpoint)
 
 	soc.Bind(TcpEndpoint)
-	res, err := monitorSoc.Recv(0)
+	// Events are followed by a frame holding the endpoint since zmq 4
+	res, err := monitorSoc.RecvMultipart(0)
 	if err != nil {
 		t.Fatal("Error when receiving monitor state", err)
 	}
-	event := res.GetEvent()
+	event := res.parts[0].GetEvent()
 	if SocketEvent(event.event) != EventListening {
 		t.Fatalf("Expected event %d, got %d", EventConnected, event.event)
 	}
 
 	soc.Close()
 
-	res, err = monitorSoc.Recv(0)
+	res, err = monitorSoc.RecvMultipart(0)
 	if err != nil {
 		t.Fatal("Error when receiving monitor state", err)
 	}
-	event = res.GetEvent()
+	event = res.parts[0].GetEvent()
 	if SocketEvent(event.event) != EventClosed {
 		t.Fatalf("Expected event %d, got %d", EventClosed, event.event)
 	}
@@ -0,0 +1,111 @@
+package zmq
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// ErrInvalidSubscription is returned when a message received on a xpub
+// socket is not a subscription event
+var ErrInvalidSubscription = errors.New("zmq: invalid subscription message")
+
+// Subscribe adds a subscription on a sub or xpub socket.
+// The prefix may contain any byte, an empty prefix subscribes to all messages.
+// On a xpub socket with XpubManual, it accepts the subscription of the peer
+// whose subscription was last received.
+// Subscriptions are counted, a prefix subscribed twice needs to be
+// unsubscribed twice.
+func (s *Socket) Subscribe(prefix []byte) error {
+	err := s.SetOptionBytes(Subscribe, prefix)
+	if err != nil {
+		return err
+	}
+	if s.subscriptions == nil {
+		s.subscriptions = map[string]int{}
+	}
+	s.subscriptions[string(prefix)]++
+	return nil
+}
+
+// Unsubscribe removes a subscription added with Subscribe
+func (s *Socket) Unsubscribe(prefix []byte) error {
+	err := s.SetOptionBytes(Unsubscribe, prefix)
+	if err != nil {
+		return err
+	}
+	key := string(prefix)
+	if s.subscriptions[key] <= 1 {
+		delete(s.subscriptions, key)
+	} else {
+		s.subscriptions[key]--
+	}
+	return nil
+}
+
+// Subscriptions returns the sorted list of active subscriptions added
+// with Subscribe. Subscriptions set with SetOptionString are not tracked.
+func (s *Socket) Subscriptions() [][]byte {
+	prefixes := make([][]byte, 0, len(s.subscriptions))
+	for prefix := range s.subscriptions {
+		prefixes = append(prefixes, []byte(prefix))
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return bytes.Compare(prefixes[i], prefixes[j]) < 0
+	})
+	return prefixes
+}
+
+// SetWelcomeMessage sets the message sent by a xpub socket to each new
+// subscriber. Subscribers need to subscribe to it to receive it.
+func (s *Socket) SetWelcomeMessage(msg []byte) error {
+	return s.SetOptionBytes(XpubWelcomeMsg, msg)
+}
+
+// SubscriptionEvent is a subscription or an unsubscription
+// received by a xpub or xsub socket
+type SubscriptionEvent struct {
+	Subscribe bool
+	Topic     []byte
+}
+
+// First byte of subscription messages
+const (
+	xpubUnsubscribe = 0
+	xpubSubscribe   = 1
+)
+
+// ParseSubscriptionEvent decodes a subscription message.
+// The first byte is 1 for a subscription and 0 for an unsubscription,
+// followed by the topic.
+func ParseSubscriptionEvent(data []byte) (SubscriptionEvent, error) {
+	if len(data) == 0 || data[0] > xpubSubscribe {
+		return SubscriptionEvent{}, ErrInvalidSubscription
+	}
+	event := SubscriptionEvent{
+		Subscribe: data[0] == xpubSubscribe,
+		Topic:     append([]byte{}, data[1:]...),
+	}
+	return event, nil
+}
+
+// Bytes encodes the event as a subscription message,
+// to be sent on a xsub socket
+func (e SubscriptionEvent) Bytes() []byte {
+	data := make([]byte, len(e.Topic)+1)
+	if e.Subscribe {
+		data[0] = xpubSubscribe
+	}
+	copy(data[1:], e.Topic)
+	return data
+}
+
+// RecvSubscription receives the next subscription event of a xpub socket
+func (s *Socket) RecvSubscription(flag SendFlag) (SubscriptionEvent, error) {
+	msg, err := s.Recv(flag)
+	if err != nil {
+		return SubscriptionEvent{}, err
+	}
+	defer msg.Close()
+	return ParseSubscriptionEvent(msg.Data)
+}
@@ -0,0 +1,149 @@
+package zmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubscribeBinaryPrefix(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pub, endpoint: TcpEndpoint, clientType: Sub}
+	env.setupEnv()
+	defer env.destroyEnv()
+	prefix := []byte{0x00, 0xff}
+	err := env.client.Subscribe(prefix)
+	if err != nil {
+		t.Fatal("Error on subscribe", err)
+	}
+	env.client.Subscribe(prefix)
+	env.client.Subscribe([]byte("other"))
+	<-time.After(time.Millisecond * 500)
+
+	expected := [][]byte{prefix, []byte("other")}
+	if !reflect.DeepEqual(env.client.Subscriptions(), expected) {
+		t.Fatalf("Expected subscriptions %q, got %q", expected, env.client.Subscriptions())
+	}
+	data := []byte{0x00, 0xff, 0x01}
+	env.server.Send([]byte{0x00, 0x01}, 0)
+	env.server.Send(data, 0)
+	response, err := env.client.Recv(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	if !reflect.DeepEqual(response.Data, data) {
+		t.Fatalf("Expected message %q, got %q", data, response.Data)
+	}
+	response.Close()
+
+	// Prefix was subscribed twice
+	env.client.Unsubscribe(prefix)
+	expected = [][]byte{prefix, []byte("other")}
+	if !reflect.DeepEqual(env.client.Subscriptions(), expected) {
+		t.Fatalf("Expected subscriptions %q, got %q", expected, env.client.Subscriptions())
+	}
+	env.client.Unsubscribe(prefix)
+	expected = [][]byte{[]byte("other")}
+	if !reflect.DeepEqual(env.client.Subscriptions(), expected) {
+		t.Fatalf("Expected subscriptions %q, got %q", expected, env.client.Subscriptions())
+	}
+}
+
+func TestSubscribeAll(t *testing.T) {
+	env := &Env{Tester: t, serverType: Pub, endpoint: TcpEndpoint, clientType: Sub}
+	env.setupEnv()
+	defer env.destroyEnv()
+	err := env.client.Subscribe(nil)
+	if err != nil {
+		t.Fatal("Error on subscribe", err)
+	}
+	<-time.After(time.Millisecond * 500)
+
+	data := []byte("any")
+	env.server.Send(data, 0)
+	response, err := env.client.Recv(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	if !reflect.DeepEqual(response.Data, data) {
+		t.Fatalf("Expected message %q, got %q", data, response.Data)
+	}
+	response.Close()
+
+	err = env.client.Unsubscribe([]byte{})
+	if err != nil {
+		t.Fatal("Error on unsubscribe", err)
+	}
+	if len(env.client.Subscriptions()) != 0 {
+		t.Fatalf("Expected no subscription, got %q", env.client.Subscriptions())
+	}
+}
+
+func TestXpubSubscriptionEvents(t *testing.T) {
+	env := &Env{Tester: t, serverType: Xpub, endpoint: TcpEndpoint, clientType: Sub}
+	env.setupServer()
+	defer env.destroyEnv()
+	err := env.server.SetOptionInt(XpubManual, 1)
+	if err != nil {
+		t.Fatal("Error on xpub manual set", err)
+	}
+	err = env.server.SetWelcomeMessage([]byte("welcome"))
+	if err != nil {
+		t.Fatal("Error on welcome message set", err)
+	}
+	env.setupClient()
+	err = env.client.Subscribe([]byte("welcome"))
+	if err != nil {
+		t.Fatal("Error on subscribe", err)
+	}
+	env.client.Subscribe([]byte("topic"))
+
+	// Welcome message is sent as soon as the subscriber connects
+	msg, err := env.client.Recv(0)
+	if err != nil {
+		t.Fatal("Error on welcome message receive", err)
+	}
+	if string(msg.Data) != "welcome" {
+		t.Fatalf("Expected welcome message, got %q", msg.Data)
+	}
+	msg.Close()
+
+	for _, topic := range []string{"welcome", "topic"} {
+		event, err := env.server.RecvSubscription(0)
+		if err != nil {
+			t.Fatal("Error on subscription receive", err)
+		}
+		expected := SubscriptionEvent{Subscribe: true, Topic: []byte(topic)}
+		if !reflect.DeepEqual(event, expected) {
+			t.Fatalf("Expected event %+v, got %+v", expected, event)
+		}
+		// Manual mode requires the subscription to be accepted
+		err = env.server.Subscribe(event.Topic)
+		if err != nil {
+			t.Fatal("Error on subscription accept", err)
+		}
+	}
+	env.server.Send([]byte("topic data"), 0)
+	msg, err = env.client.Recv(0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	if string(msg.Data) != "topic data" {
+		t.Fatalf("Expected message 'topic data', got %q", msg.Data)
+	}
+	msg.Close()
+}
+
+func TestParseSubscriptionEvent(t *testing.T) {
+	event := SubscriptionEvent{Subscribe: false, Topic: []byte("topic")}
+	parsed, err := ParseSubscriptionEvent(event.Bytes())
+	if err != nil {
+		t.Fatal("Error on subscription event parsing", err)
+	}
+	if !reflect.DeepEqual(parsed, event) {
+		t.Fatalf("Expected event %+v, got %+v", event, parsed)
+	}
+	_, err = ParseSubscriptionEvent([]byte{})
+	if err != ErrInvalidSubscription {
+		t.Fatalf("Expected error %q, got %q", ErrInvalidSubscription, err)
+	}
+}
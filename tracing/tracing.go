@@ -0,0 +1,104 @@
+// Package tracing propagates the W3C trace context of OpenTelemetry in
+// multipart messages, so traces follow messages across sockets.
+//
+// The traceparent and tracestate of the sender context are carried by a
+// header frame appended to the message. It is the last frame so topics of
+// pub/sub messages and envelopes of router messages are unchanged.
+// Messages sent without a valid span context have no header frame.
+//
+//	err := tracing.SendMultipart(ctx, soc, frames, 0)
+//
+//	ctx, msg, err := tracing.RecvMultipart(context.Background(), soc, 0)
+//	ctx, span := tracer.Start(ctx, "process")
+package tracing
+
+import (
+	"bytes"
+	"context"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// headerPrefix starts header frames, it can not start a text frame
+var headerPrefix = []byte("\x00zmq-trace\x00")
+
+var propagator = propagation.TraceContext{}
+
+// header is the carrier of the trace context, encoded as
+// "key: value" lines
+type header map[string]string
+
+func (h header) Get(key string) string {
+	return h[key]
+}
+
+func (h header) Set(key, value string) {
+	h[key] = value
+}
+
+func (h header) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Inject appends a header frame holding the trace context of ctx to the
+// frames. The frames are returned unchanged when ctx has no trace context.
+func Inject(ctx context.Context, frames [][]byte) [][]byte {
+	h := header{}
+	propagator.Inject(ctx, h)
+	if len(h) == 0 {
+		return frames
+	}
+	frame := append([]byte{}, headerPrefix...)
+	// Fields are written in a fixed order
+	for _, key := range propagator.Fields() {
+		if value, ok := h[key]; ok {
+			frame = append(frame, key+": "+value+"\n"...)
+		}
+	}
+	return append(frames[:len(frames):len(frames)], frame)
+}
+
+// IsHeader reports whether the frame is a header frame written by Inject
+func IsHeader(frame []byte) bool {
+	return bytes.HasPrefix(frame, headerPrefix)
+}
+
+// Extract returns ctx with the trace context carried by the last frame of
+// a message, and the frames without this header frame. Messages without
+// header frame are returned unchanged with ctx.
+func Extract(ctx context.Context, frames [][]byte) (context.Context, [][]byte) {
+	if len(frames) == 0 || !IsHeader(frames[len(frames)-1]) {
+		return ctx, frames
+	}
+	h := header{}
+	lines := bytes.Split(frames[len(frames)-1][len(headerPrefix):], []byte("\n"))
+	for _, line := range lines {
+		key, value, ok := bytes.Cut(line, []byte(": "))
+		if ok {
+			h.Set(string(key), string(value))
+		}
+	}
+	return propagator.Extract(ctx, h), frames[:len(frames)-1]
+}
+
+// SendMultipart sends a message with the trace context of ctx
+func SendMultipart(ctx context.Context, soc *zmq.Socket, frames [][]byte, flag zmq.SendFlag) error {
+	return soc.SendMultipart(Inject(ctx, frames), flag)
+}
+
+// RecvMultipart receives a message and returns ctx with the trace context
+// of the sender. The header frame is removed from the data of the message
+// and is released when the message is closed.
+func RecvMultipart(ctx context.Context, soc *zmq.Socket, flag zmq.SendFlag) (context.Context, *zmq.MessageMultipart, error) {
+	msg, err := soc.RecvMultipart(flag)
+	if err != nil {
+		return ctx, nil, err
+	}
+	ctx, msg.Data = Extract(ctx, msg.Data)
+	return ctx, msg, nil
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanContext(t *testing.T) context.Context {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	state, err := trace.ParseTraceState("vendor=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		TraceState: state,
+		Remote:     true,
+	})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func TestInjectExtract(t *testing.T) {
+	ctx := spanContext(t)
+	frames := [][]byte{[]byte("topic"), []byte("data")}
+	injected := Inject(ctx, frames)
+	if len(injected) != 3 || !IsHeader(injected[2]) {
+		t.Fatalf("Expected a header frame, got %q", injected)
+	}
+	expected := "\x00zmq-trace\x00traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\ntracestate: vendor=value\n"
+	if string(injected[2]) != expected {
+		t.Fatalf("Expected header %q, got %q", expected, injected[2])
+	}
+	extractedCtx, extracted := Extract(context.Background(), injected)
+	if !reflect.DeepEqual(extracted, frames) {
+		t.Fatalf("Expected frames %q, got %q", frames, extracted)
+	}
+	sc := trace.SpanContextFromContext(extractedCtx)
+	if !sc.Equal(trace.SpanContextFromContext(ctx)) {
+		t.Fatalf("Expected span context %v, got %v", trace.SpanContextFromContext(ctx), sc)
+	}
+}
+
+func TestNoTraceContext(t *testing.T) {
+	frames := [][]byte{[]byte("data")}
+	if injected := Inject(context.Background(), frames); len(injected) != 1 {
+		t.Fatalf("Expected no header frame, got %q", injected)
+	}
+	ctx, extracted := Extract(context.Background(), frames)
+	if !reflect.DeepEqual(extracted, frames) || trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatalf("Expected unchanged frames, got %q", extracted)
+	}
+}
+
+func TestSendRecvMultipart(t *testing.T) {
+	zctx, _ := zmq.NewContext()
+	defer zctx.Destroy()
+	push, _ := zctx.NewSocket(zmq.Push)
+	defer push.Close()
+	pull, _ := zctx.NewSocket(zmq.Pull)
+	defer pull.Close()
+	pull.Bind("inproc://tracing")
+	push.Connect("inproc://tracing")
+
+	ctx := spanContext(t)
+	frames := [][]byte{[]byte("topic"), []byte("data")}
+	err := SendMultipart(ctx, push, frames, 0)
+	if err != nil {
+		t.Fatal("Error on send", err)
+	}
+	received, msg, err := RecvMultipart(context.Background(), pull, 0)
+	if err != nil {
+		t.Fatal("Error on receive", err)
+	}
+	defer msg.Close()
+	if !reflect.DeepEqual(msg.Data, frames) {
+		t.Fatalf("Expected frames %q, got %q", frames, msg.Data)
+	}
+	if trace.SpanContextFromContext(received).TraceID() != trace.SpanContextFromContext(ctx).TraceID() {
+		t.Fatal("Expected trace context of the sender")
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !purego
+
+package zmq
+
+/*
+#cgo pkg-config: libzmq
+#include <zmq.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Version returns the version of the zmq library
+func Version() (major, minor, patch int) {
+	var cmajor, cminor, cpatch C.int
+	C.zmq_version(&cmajor, &cminor, &cpatch)
+	return int(cmajor), int(cminor), int(cpatch)
+}
+
+// headerVersion returns the version of the zmq.h header the binding was
+// built with
+func headerVersion() (major, minor, patch int) {
+	return C.ZMQ_VERSION_MAJOR, C.ZMQ_VERSION_MINOR, C.ZMQ_VERSION_PATCH
+}
+
+// has asks the zmq library whether the capability is available
+func has(capability Capability) bool {
+	cstr := C.CString(string(capability))
+	defer C.free(unsafe.Pointer(cstr))
+	return C.zmq_has(cstr) == 1
+}
@@ -0,0 +1,28 @@
+//go:build purego
+
+package zmq
+
+// Version of libzmq the pure Go backend is compatible with, for ZMTP 3.1
+// peers
+const (
+	versionMajor = 4
+	versionMinor = 3
+	versionPatch = 5
+)
+
+// Version returns the version of libzmq the pure Go backend is
+// compatible with
+func Version() (major, minor, patch int) {
+	return versionMajor, versionMinor, versionPatch
+}
+
+// headerVersion returns the version of libzmq the backend follows
+func headerVersion() (major, minor, patch int) {
+	return versionMajor, versionMinor, versionPatch
+}
+
+// has reports the capabilities of the pure Go backend, which implements
+// the tcp, ipc and inproc transports with the NULL and PLAIN mechanisms
+func has(capability Capability) bool {
+	return capability == CapIpc
+}
@@ -0,0 +1,375 @@
+package zmqtest
+
+import (
+	"bytes"
+	"sync"
+	"syscall"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// received is a scripted message, or error, returned by a fake socket
+type received struct {
+	frames [][]byte
+	err    error
+}
+
+// FakeSocket is an in-memory socket for unit tests of code using
+// zmq.SocketAPI. Messages to receive and send errors are scripted, sent
+// messages are recorded. Frames sent with SndMore are recorded with the
+// last frame of their message. Request and reply sockets return
+// zmq.ErrFSM like libzmq when they send or receive out of turn.
+//
+// A receive without scripted message returns EAGAIN, as if the receive
+// timeout expired, instead of blocking.
+//
+//	soc := zmqtest.NewFakeSocket(zmq.Req)
+//	soc.QueueRecv([]byte("pong"))
+//	reply, err := client.Ping(soc)
+//	sent := soc.Sent()
+type FakeSocket struct {
+	socketType zmq.SocketType
+
+	mu            sync.Mutex
+	queue         []received
+	sendErrors    []error
+	sent          [][][]byte
+	sendBuf       [][]byte
+	recvBuf       [][]byte
+	endpoints     []string
+	subscriptions [][]byte
+	options       map[zmq.SocketOptionInt]int
+	bytesOptions  map[zmq.SocketOptionString][]byte
+	// waiting is true when a request socket waits for its reply or
+	// a reply socket has to reply
+	waiting bool
+	closed  bool
+}
+
+var _ zmq.SocketAPI = (*FakeSocket)(nil)
+
+// NewFakeSocket creates a fake socket of the given type
+func NewFakeSocket(socketType zmq.SocketType) *FakeSocket {
+	return &FakeSocket{
+		socketType:   socketType,
+		options:      map[zmq.SocketOptionInt]int{},
+		bytesOptions: map[zmq.SocketOptionString][]byte{},
+	}
+}
+
+// QueueRecv scripts a message to receive. Messages are received in the
+// order they are queued.
+func (s *FakeSocket) QueueRecv(parts ...[]byte) {
+	if len(parts) == 0 {
+		parts = [][]byte{{}}
+	}
+	s.mu.Lock()
+	s.queue = append(s.queue, received{frames: copyParts(parts)})
+	s.mu.Unlock()
+}
+
+// QueueRecvError scripts an error returned by a receive in place of
+// a message
+func (s *FakeSocket) QueueRecvError(err error) {
+	s.mu.Lock()
+	s.queue = append(s.queue, received{err: err})
+	s.mu.Unlock()
+}
+
+// QueueSendError scripts an error returned by the first frame of the
+// next message sent. The message is not recorded.
+func (s *FakeSocket) QueueSendError(err error) {
+	s.mu.Lock()
+	s.sendErrors = append(s.sendErrors, err)
+	s.mu.Unlock()
+}
+
+// Sent returns the messages sent
+func (s *FakeSocket) Sent() [][][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := make([][][]byte, len(s.sent))
+	for i, msg := range s.sent {
+		sent[i] = copyParts(msg)
+	}
+	return sent
+}
+
+// Endpoints returns the bound and connected endpoints
+func (s *FakeSocket) Endpoints() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.endpoints...)
+}
+
+// Subscriptions returns the subscribed prefixes
+func (s *FakeSocket) Subscriptions() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyParts(s.subscriptions)
+}
+
+// Closed reports whether the socket is closed
+func (s *FakeSocket) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Send records a frame, the message is recorded with its last frame
+func (s *FakeSocket) Send(data []byte, flag zmq.SendFlag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return syscall.ENOTSOCK
+	}
+	if len(s.sendBuf) == 0 {
+		if err := s.checkSend(); err != nil {
+			return err
+		}
+		if len(s.sendErrors) > 0 {
+			err := s.sendErrors[0]
+			s.sendErrors = s.sendErrors[1:]
+			return err
+		}
+	}
+	s.sendBuf = append(s.sendBuf, append([]byte{}, data...))
+	if flag&zmq.SndMore != 0 {
+		return nil
+	}
+	s.sent = append(s.sent, s.sendBuf)
+	s.sendBuf = nil
+	switch s.socketType {
+	case zmq.Req:
+		s.waiting = true
+	case zmq.Rep:
+		s.waiting = false
+	}
+	return nil
+}
+
+// SendMultipart records a message with one or several frames
+func (s *FakeSocket) SendMultipart(data [][]byte, flag zmq.SendFlag) error {
+	for i, frame := range data {
+		frameFlag := flag
+		if i < len(data)-1 {
+			frameFlag |= zmq.SndMore
+		}
+		err := s.Send(frame, frameFlag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recv returns the next frame of the scripted messages
+func (s *FakeSocket) Recv(flag zmq.SendFlag) (*zmq.MessagePart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, syscall.ENOTSOCK
+	}
+	if len(s.recvBuf) == 0 {
+		if err := s.checkRecv(); err != nil {
+			return nil, err
+		}
+		if len(s.queue) == 0 {
+			return nil, syscall.EAGAIN
+		}
+		next := s.queue[0]
+		s.queue = s.queue[1:]
+		if next.err != nil {
+			return nil, next.err
+		}
+		s.recvBuf = next.frames
+	}
+	frame := s.recvBuf[0]
+	s.recvBuf = s.recvBuf[1:]
+	more := len(s.recvBuf) > 0
+	if !more {
+		switch s.socketType {
+		case zmq.Req:
+			s.waiting = false
+		case zmq.Rep:
+			s.waiting = true
+		}
+	}
+	return zmq.NewMessagePart(frame, more), nil
+}
+
+// RecvMultipart returns the next scripted message
+func (s *FakeSocket) RecvMultipart(flag zmq.SendFlag) (*zmq.MessageMultipart, error) {
+	var frames [][]byte
+	for {
+		part, err := s.Recv(flag)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, part.Data)
+		if !part.HasMore() {
+			return zmq.NewMessageMultipart(frames), nil
+		}
+	}
+}
+
+// checkSend validates the socket state before sending a new message,
+// it is called with the mutex held
+func (s *FakeSocket) checkSend() error {
+	switch s.socketType {
+	case zmq.Sub, zmq.Pull:
+		return syscall.ENOTSUP
+	case zmq.Req:
+		if s.waiting {
+			return zmq.ErrFSM
+		}
+	case zmq.Rep:
+		if !s.waiting {
+			return zmq.ErrFSM
+		}
+	}
+	return nil
+}
+
+// checkRecv validates the socket state before receiving a new message,
+// it is called with the mutex held
+func (s *FakeSocket) checkRecv() error {
+	switch s.socketType {
+	case zmq.Pub, zmq.Push:
+		return syscall.ENOTSUP
+	case zmq.Req:
+		if !s.waiting {
+			return zmq.ErrFSM
+		}
+	case zmq.Rep:
+		if s.waiting {
+			return zmq.ErrFSM
+		}
+	}
+	return nil
+}
+
+// Bind records the endpoint
+func (s *FakeSocket) Bind(address string) error {
+	return s.addEndpoint(address)
+}
+
+// Unbind removes a bound endpoint
+func (s *FakeSocket) Unbind(address string) error {
+	return s.removeEndpoint(address)
+}
+
+// Connect records the endpoint
+func (s *FakeSocket) Connect(address string) error {
+	return s.addEndpoint(address)
+}
+
+// Disconnect removes a connected endpoint
+func (s *FakeSocket) Disconnect(address string) error {
+	return s.removeEndpoint(address)
+}
+
+func (s *FakeSocket) addEndpoint(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return syscall.ENOTSOCK
+	}
+	s.endpoints = append(s.endpoints, address)
+	return nil
+}
+
+func (s *FakeSocket) removeEndpoint(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return syscall.ENOTSOCK
+	}
+	for i, endpoint := range s.endpoints {
+		if endpoint == address {
+			s.endpoints = append(s.endpoints[:i], s.endpoints[i+1:]...)
+			return nil
+		}
+	}
+	return syscall.ENOENT
+}
+
+// Close closes the socket, later calls fail with ENOTSOCK
+func (s *FakeSocket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return syscall.ENOTSOCK
+	}
+	s.closed = true
+	return nil
+}
+
+// Subscribe records a subscription of a subscriber socket
+func (s *FakeSocket) Subscribe(prefix []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.socketType != zmq.Sub && s.socketType != zmq.Xsub {
+		return syscall.EINVAL
+	}
+	s.subscriptions = append(s.subscriptions, append([]byte{}, prefix...))
+	return nil
+}
+
+// Unsubscribe removes a subscription of a subscriber socket
+func (s *FakeSocket) Unsubscribe(prefix []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.socketType != zmq.Sub && s.socketType != zmq.Xsub {
+		return syscall.EINVAL
+	}
+	for i, subscription := range s.subscriptions {
+		if bytes.Equal(subscription, prefix) {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// GetOptionInt returns the type of the socket for zmq.Type and the
+// value set for other options
+func (s *FakeSocket) GetOptionInt(option zmq.SocketOptionInt) (int, error) {
+	if option == zmq.Type {
+		return int(s.socketType), nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.options[option], nil
+}
+
+// SetOptionInt records the value of the option
+func (s *FakeSocket) SetOptionInt(option zmq.SocketOptionInt, value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options[option] = value
+	return nil
+}
+
+// GetOptionBytes returns the value set for the option
+func (s *FakeSocket) GetOptionBytes(option zmq.SocketOptionString) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte{}, s.bytesOptions[option]...), nil
+}
+
+// SetOptionBytes records the value of the option
+func (s *FakeSocket) SetOptionBytes(option zmq.SocketOptionString, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesOptions[option] = append([]byte{}, value...)
+	return nil
+}
+
+func copyParts(parts [][]byte) [][]byte {
+	copied := make([][]byte, len(parts))
+	for i, part := range parts {
+		copied[i] = append([]byte{}, part...)
+	}
+	return copied
+}
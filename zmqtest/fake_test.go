@@ -0,0 +1,119 @@
+package zmqtest
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// ping is application code using a socket through the interface
+func ping(soc zmq.SocketAPI) ([][]byte, error) {
+	err := soc.SendMultipart([][]byte{[]byte("ping"), []byte("1")}, 0)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := soc.RecvMultipart(0)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Close()
+	return msg.Data, nil
+}
+
+func TestFakeRequest(t *testing.T) {
+	soc := NewFakeSocket(zmq.Req)
+	soc.QueueRecv([]byte("pong"), []byte("2"))
+	reply, err := ping(soc)
+	if err != nil {
+		t.Fatal("Error on ping", err)
+	}
+	if !reflect.DeepEqual(reply, [][]byte{[]byte("pong"), []byte("2")}) {
+		t.Fatalf("Unexpected reply %q", reply)
+	}
+	expected := [][][]byte{{[]byte("ping"), []byte("1")}}
+	if !reflect.DeepEqual(soc.Sent(), expected) {
+		t.Fatalf("Expected sent messages %q, got %q", expected, soc.Sent())
+	}
+
+	_, err = soc.Recv(0)
+	if err != zmq.ErrFSM {
+		t.Fatalf("Expected EFSM on receive before request, got %v", err)
+	}
+	soc.Send([]byte("a"), 0)
+	err = soc.Send([]byte("b"), 0)
+	if err != zmq.ErrFSM {
+		t.Fatalf("Expected EFSM on second request, got %v", err)
+	}
+	_, err = soc.Recv(zmq.DontWait)
+	if err != syscall.EAGAIN {
+		t.Fatalf("Expected EAGAIN without scripted reply, got %v", err)
+	}
+}
+
+func TestFakeReply(t *testing.T) {
+	soc := NewFakeSocket(zmq.Rep)
+	err := soc.Send([]byte("early"), 0)
+	if err != zmq.ErrFSM {
+		t.Fatalf("Expected EFSM on reply before request, got %v", err)
+	}
+	soc.QueueRecv([]byte("a"), []byte("b"))
+	part, err := soc.Recv(0)
+	if err != nil || string(part.Data) != "a" || !part.HasMore() {
+		t.Fatalf("Expected first frame with more flag, got %v %v", part, err)
+	}
+	err = soc.Send([]byte("partial"), 0)
+	if err != zmq.ErrFSM {
+		t.Fatalf("Expected EFSM before the request is received, got %v", err)
+	}
+	part, err = soc.Recv(0)
+	if err != nil || string(part.Data) != "b" || part.HasMore() {
+		t.Fatalf("Expected last frame, got %v %v", part, err)
+	}
+	part.Close()
+	err = soc.Send([]byte("reply"), 0)
+	if err != nil {
+		t.Fatal("Error on reply", err)
+	}
+}
+
+func TestFakeScriptedErrors(t *testing.T) {
+	soc := NewFakeSocket(zmq.Dealer)
+	failure := errors.New("failure")
+	soc.QueueSendError(failure)
+	soc.QueueRecvError(syscall.EINTR)
+	soc.QueueRecv([]byte("data"))
+	err := soc.SendMultipart([][]byte{[]byte("a"), []byte("b")}, 0)
+	if err != failure {
+		t.Fatalf("Expected scripted send error, got %v", err)
+	}
+	if len(soc.Sent()) != 0 {
+		t.Fatalf("Unexpected sent messages %q", soc.Sent())
+	}
+	_, err = soc.RecvMultipart(0)
+	if err != syscall.EINTR {
+		t.Fatalf("Expected scripted receive error, got %v", err)
+	}
+	msg, err := soc.RecvMultipart(0)
+	if err != nil || len(msg.Data) != 1 || string(msg.Data[0]) != "data" {
+		t.Fatalf("Expected scripted message, got %v %v", msg, err)
+	}
+
+	soc.Connect("tcp://127.0.0.1:5555")
+	if err := soc.Disconnect("tcp://127.0.0.1:5556"); err != syscall.ENOENT {
+		t.Fatalf("Expected ENOENT on unknown endpoint, got %v", err)
+	}
+	if err := soc.Subscribe([]byte("topic")); err != syscall.EINVAL {
+		t.Fatalf("Expected EINVAL on subscribe of a dealer, got %v", err)
+	}
+	socketType, _ := soc.GetOptionInt(zmq.Type)
+	if zmq.SocketType(socketType) != zmq.Dealer {
+		t.Fatalf("Unexpected socket type %d", socketType)
+	}
+	soc.Close()
+	if err := soc.Send([]byte("a"), 0); err != syscall.ENOTSOCK || !soc.Closed() {
+		t.Fatalf("Expected ENOTSOCK after close, got %v", err)
+	}
+}
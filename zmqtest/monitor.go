@@ -0,0 +1,70 @@
+package zmqtest
+
+import (
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// Monitor receives the monitor events of a socket
+type Monitor struct {
+	pair *zmq.Socket
+}
+
+// NewMonitor monitors the given events of the socket. Events are
+// received by a pair socket closed when the test completes.
+func NewMonitor(t testing.TB, ctx *zmq.Context, soc *zmq.Socket, events zmq.SocketEvent) *Monitor {
+	t.Helper()
+	endpoint := InprocEndpoint(t)
+	err := soc.Monitor(endpoint, events)
+	if err != nil {
+		t.Fatal("zmqtest: monitor:", err)
+	}
+	pair := NewSocket(t, ctx, zmq.Pair)
+	err = pair.Connect(endpoint)
+	if err != nil {
+		t.Fatalf("zmqtest: connect %s: %v", endpoint, err)
+	}
+	return &Monitor{pair: pair}
+}
+
+// ExpectEvent fails the test unless the event is received within the
+// timeout. Events received before are discarded.
+func (m *Monitor) ExpectEvent(t testing.TB, event zmq.SocketEvent, timeout time.Duration) zmq.MonitorEvent {
+	t.Helper()
+	var received []string
+	items := zmq.PollItems{&zmq.PollItem{Socket: m.pair, Events: zmq.Pollin}}
+	deadline := time.Now().Add(timeout)
+	for {
+		n, err := items.Poll(max(time.Until(deadline), 0))
+		if err != nil {
+			t.Fatal("zmqtest: poll:", err)
+		}
+		if n == 0 {
+			t.Fatalf("zmqtest: event %s not received in %s, got %v", event, timeout, received)
+		}
+		e, err := m.pair.RecvMonitorEvent(0)
+		if err != nil {
+			t.Fatal("zmqtest: receive monitor event:", err)
+		}
+		if e.Event == event {
+			return e
+		}
+		received = append(received, e.Event.String())
+	}
+}
+
+// ExpectDisconnected fails the test unless a connection of the socket
+// is lost within the timeout
+func (m *Monitor) ExpectDisconnected(t testing.TB, timeout time.Duration) zmq.MonitorEvent {
+	t.Helper()
+	return m.ExpectEvent(t, zmq.EventDisconnected, timeout)
+}
+
+// ExpectConnectRetried fails the test unless the socket tries to
+// reconnect within the timeout
+func (m *Monitor) ExpectConnectRetried(t testing.TB, timeout time.Duration) zmq.MonitorEvent {
+	t.Helper()
+	return m.ExpectEvent(t, zmq.EventConnectRetried, timeout)
+}
@@ -0,0 +1,277 @@
+package zmqtest
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bonnefoa/go-zeromq/zmtp"
+)
+
+// faults applied to the messages forwarded by a proxy
+type faults struct {
+	drop      bool
+	duplicate bool
+	truncate  bool
+	delay     time.Duration
+}
+
+// Proxy is a tcp proxy between sockets which injects faults in the
+// connections: messages can be dropped, delayed, duplicated or truncated
+// and connections cut or refused. Greetings and commands are always
+// forwarded, faults apply to the messages of both directions.
+//
+//	proxy := zmqtest.NewProxy(t, zmqtest.TCPEndpoint(t))
+//	server.Bind(target)
+//	client.Connect(proxy.Endpoint())
+//	proxy.Drop(true)
+type Proxy struct {
+	t       testing.TB
+	target  string
+	address string
+
+	mu       sync.Mutex
+	faults   faults
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewProxy creates a proxy forwarding the connections accepted on a free
+// port of the loopback interface to the target tcp endpoint. The proxy
+// is closed when the test completes.
+func NewProxy(t testing.TB, target string) *Proxy {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("zmqtest: proxy listen:", err)
+	}
+	p := &Proxy{
+		t:        t,
+		target:   strings.TrimPrefix(target, "tcp://"),
+		address:  l.Addr().String(),
+		listener: l,
+		conns:    map[net.Conn]struct{}{},
+	}
+	p.wg.Add(1)
+	go p.serve(l)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Endpoint returns the endpoint to connect to instead of the target
+func (p *Proxy) Endpoint() string {
+	return "tcp://" + p.address
+}
+
+// Drop drops the messages while enabled
+func (p *Proxy) Drop(enabled bool) {
+	p.mu.Lock()
+	p.faults.drop = enabled
+	p.mu.Unlock()
+}
+
+// Duplicate forwards the messages twice while enabled
+func (p *Proxy) Duplicate(enabled bool) {
+	p.mu.Lock()
+	p.faults.duplicate = enabled
+	p.mu.Unlock()
+}
+
+// Truncate forwards the first half of the messages then cuts their
+// connection while enabled
+func (p *Proxy) Truncate(enabled bool) {
+	p.mu.Lock()
+	p.faults.truncate = enabled
+	p.mu.Unlock()
+}
+
+// Delay delays the messages, a zero delay forwards them immediately
+func (p *Proxy) Delay(delay time.Duration) {
+	p.mu.Lock()
+	p.faults.delay = delay
+	p.mu.Unlock()
+}
+
+// Cut closes the established connections. Sockets reconnect through the
+// proxy.
+func (p *Proxy) Cut() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+}
+
+// Pause cuts the connections and refuses new ones until Resume
+func (p *Proxy) Pause() {
+	p.mu.Lock()
+	l := p.listener
+	p.listener = nil
+	p.mu.Unlock()
+	if l != nil {
+		l.Close()
+	}
+	p.Cut()
+}
+
+// Resume accepts connections again on the same address after Pause
+func (p *Proxy) Resume() {
+	p.t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener != nil || p.closed {
+		return
+	}
+	l, err := net.Listen("tcp", p.address)
+	if err != nil {
+		p.t.Fatal("zmqtest: proxy listen:", err)
+	}
+	p.listener = l
+	p.wg.Add(1)
+	go p.serve(l)
+}
+
+// Close stops the proxy and closes its connections
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.Pause()
+	p.wg.Wait()
+}
+
+// serve accepts connections until the listener is closed
+func (p *Proxy) serve(l net.Listener) {
+	defer p.wg.Done()
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		if !p.track(client, server) {
+			client.Close()
+			server.Close()
+			return
+		}
+		p.wg.Add(1)
+		go p.handle(client, server)
+	}
+}
+
+// track registers the connections unless the proxy is closed
+func (p *Proxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	for _, conn := range conns {
+		p.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+// handle forwards a connection in both directions until one side closes
+func (p *Proxy) handle(client, server net.Conn) {
+	defer p.wg.Done()
+	done := make(chan struct{}, 2)
+	go func() {
+		p.pump(client, server)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.pump(server, client)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+	p.mu.Lock()
+	delete(p.conns, client)
+	delete(p.conns, server)
+	p.mu.Unlock()
+}
+
+// forwarder writes the data read from the connection to its destination
+// while the greetings are exchanged
+type forwarder struct {
+	r          io.Reader
+	w          io.Writer
+	forwarding bool
+	forwarded  int
+}
+
+func (f *forwarder) Read(data []byte) (int, error) {
+	n, err := f.r.Read(data)
+	if f.forwarding && n > 0 {
+		f.w.Write(data[:n])
+		f.forwarded += n
+	}
+	return n, err
+}
+
+// pump forwards the data sent by one side to the other. Greetings are
+// forwarded as they are received since ZMTP 3 peers wait for parts of the
+// greeting of their peer, messages are forwarded once decoded so faults
+// can be applied.
+func (p *Proxy) pump(src io.Reader, dst io.Writer) {
+	f := &forwarder{r: src, w: dst, forwarding: true}
+	d := zmtp.NewDecoder(f)
+	_, err := d.ReadGreeting()
+	if err != nil {
+		return
+	}
+	f.forwarding = false
+	// Data read ahead with the greeting was already forwarded
+	skip := f.forwarded - len(d.Raw())
+	for {
+		_, cmd, err := d.ReadMessage()
+		if err != nil {
+			return
+		}
+		raw := d.Raw()
+		sent := 0
+		if skip > 0 {
+			sent = min(skip, len(raw))
+			skip -= sent
+		}
+		if cmd != nil || sent > 0 {
+			_, err = dst.Write(raw[sent:])
+			if err != nil {
+				return
+			}
+			continue
+		}
+		p.mu.Lock()
+		fault := p.faults
+		p.mu.Unlock()
+		if fault.drop {
+			continue
+		}
+		if fault.delay > 0 {
+			time.Sleep(fault.delay)
+		}
+		if fault.truncate {
+			dst.Write(raw[:len(raw)/2])
+			return
+		}
+		_, err = dst.Write(raw)
+		if err == nil && fault.duplicate {
+			_, err = dst.Write(raw)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
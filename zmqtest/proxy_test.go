@@ -0,0 +1,101 @@
+package zmqtest
+
+import (
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+func TestProxyFaults(t *testing.T) {
+	ctx := NewContext(t)
+	target := TCPEndpoint(t)
+	proxy := NewProxy(t, target)
+	pull := NewSocket(t, ctx, zmq.Pull)
+	err := pull.Bind(target)
+	if err != nil {
+		t.Fatal("Error on bind", err)
+	}
+	push := NewSocket(t, ctx, zmq.Push)
+	monitor := NewMonitor(t, ctx, push, zmq.EventAll)
+	err = push.Connect(proxy.Endpoint())
+	if err != nil {
+		t.Fatal("Error on connect", err)
+	}
+	monitor.ExpectEvent(t, zmq.EventConnected, time.Second)
+
+	Send(t, push, []byte("a"), []byte("1"))
+	ExpectMultipart(t, pull, time.Second, []byte("a"), []byte("1"))
+
+	proxy.Drop(true)
+	Send(t, push, []byte("b"))
+	ExpectNoMessage(t, pull, 100*time.Millisecond)
+	proxy.Drop(false)
+
+	proxy.Duplicate(true)
+	Send(t, push, []byte("c"))
+	ExpectMultipart(t, pull, time.Second, []byte("c"))
+	ExpectMultipart(t, pull, time.Second, []byte("c"))
+	proxy.Duplicate(false)
+
+	proxy.Delay(100 * time.Millisecond)
+	start := time.Now()
+	Send(t, push, []byte("d"))
+	ExpectMultipart(t, pull, time.Second, []byte("d"))
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected a delayed message, received after %s", elapsed)
+	}
+	proxy.Delay(0)
+
+	proxy.Truncate(true)
+	Send(t, push, []byte("truncated"))
+	monitor.ExpectDisconnected(t, time.Second)
+	proxy.Truncate(false)
+	monitor.ExpectConnectRetried(t, time.Second)
+	monitor.ExpectEvent(t, zmq.EventConnected, time.Second)
+	Send(t, push, []byte("e"))
+	ExpectMultipart(t, pull, time.Second, []byte("e"))
+}
+
+func TestProxyCut(t *testing.T) {
+	ctx := NewContext(t)
+	target := TCPEndpoint(t)
+	proxy := NewProxy(t, target)
+	rep := NewSocket(t, ctx, zmq.Rep)
+	err := rep.Bind(target)
+	if err != nil {
+		t.Fatal("Error on bind", err)
+	}
+	dealer := NewSocket(t, ctx, zmq.Dealer)
+	monitor := NewMonitor(t, ctx, dealer, zmq.EventAll)
+	err = dealer.Connect(proxy.Endpoint())
+	if err != nil {
+		t.Fatal("Error on connect", err)
+	}
+	monitor.ExpectEvent(t, zmq.EventConnected, time.Second)
+	// The connection is established through the proxy once a message
+	// is exchanged
+	Send(t, dealer, []byte{}, []byte("ping"))
+	ExpectMultipart(t, rep, time.Second, []byte("ping"))
+	Send(t, rep, []byte("pong"))
+	ExpectMultipart(t, dealer, time.Second, []byte{}, []byte("pong"))
+
+	proxy.Cut()
+	monitor.ExpectDisconnected(t, time.Second)
+	monitor.ExpectConnectRetried(t, time.Second)
+	monitor.ExpectEvent(t, zmq.EventConnected, time.Second)
+	Send(t, dealer, []byte{}, []byte("ping"))
+	ExpectMultipart(t, rep, time.Second, []byte("ping"))
+	Send(t, rep, []byte("pong"))
+	ExpectMultipart(t, dealer, time.Second, []byte{}, []byte("pong"))
+
+	proxy.Pause()
+	monitor.ExpectDisconnected(t, time.Second)
+	monitor.ExpectConnectRetried(t, time.Second)
+	monitor.ExpectConnectRetried(t, time.Second)
+	proxy.Resume()
+	Send(t, dealer, []byte{}, []byte("ping"))
+	ExpectMultipart(t, rep, time.Second, []byte("ping"))
+	Send(t, rep, []byte("pong"))
+	ExpectMultipart(t, dealer, time.Second, []byte{}, []byte("pong"))
+}
@@ -0,0 +1,170 @@
+// Package zmqtest provides helpers for tests using sockets: contexts and
+// sockets closed with the test, free tcp ports and unique inproc
+// endpoints, connected socket pairs and assertions on received messages.
+//
+//	ctx := zmqtest.NewContext(t)
+//	server, client := zmqtest.Pair(t, ctx, zmq.Rep, zmq.Req, zmqtest.TCPEndpoint(t))
+//	zmqtest.Send(t, client, []byte("ping"))
+//	zmqtest.ExpectMultipart(t, server, time.Second, []byte("ping"))
+package zmqtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// Time given to a context to shut down when the test completes
+const shutdownTimeout = 5 * time.Second
+
+// Sequence of the inproc endpoints
+var inprocs uint64
+
+// NewContext creates a context shut down when the test completes,
+// closing the sockets left open
+func NewContext(t testing.TB) *zmq.Context {
+	t.Helper()
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatal("zmqtest: context creation:", err)
+	}
+	t.Cleanup(func() {
+		c, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := ctx.Shutdown(c)
+		if err != nil {
+			t.Error("zmqtest: context shutdown:", err)
+		}
+	})
+	return ctx
+}
+
+// NewSocket creates a socket closed when the test completes. The socket
+// does not linger so closing it never blocks.
+func NewSocket(t testing.TB, ctx *zmq.Context, socketType zmq.SocketType) *zmq.Socket {
+	t.Helper()
+	soc, err := ctx.NewSocket(socketType)
+	if err != nil {
+		t.Fatalf("zmqtest: %s socket creation: %v", socketType, err)
+	}
+	err = soc.SetOptionInt(zmq.Linger, 0)
+	if err != nil {
+		soc.Close()
+		t.Fatal("zmqtest: set linger:", err)
+	}
+	t.Cleanup(func() { soc.Close() })
+	return soc
+}
+
+// TCPEndpoint returns an endpoint on a free port of the loopback
+// interface. The port is free when the endpoint is returned, another
+// process may still take it before it is bound.
+func TCPEndpoint(t testing.TB) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("zmqtest: free port:", err)
+	}
+	defer l.Close()
+	return "tcp://" + l.Addr().String()
+}
+
+// InprocEndpoint returns an inproc endpoint unique in the process, named
+// after the test
+func InprocEndpoint(t testing.TB) string {
+	name := strings.NewReplacer("/", "-", " ", "-").Replace(t.Name())
+	return fmt.Sprintf("inproc://%s-%d", name, atomic.AddUint64(&inprocs, 1))
+}
+
+// Pair creates a server socket bound to the endpoint and a client socket
+// connected to it, closed when the test completes
+func Pair(t testing.TB, ctx *zmq.Context, serverType, clientType zmq.SocketType, endpoint string) (server, client *zmq.Socket) {
+	t.Helper()
+	server = NewSocket(t, ctx, serverType)
+	err := server.Bind(endpoint)
+	if err != nil {
+		t.Fatalf("zmqtest: bind %s: %v", endpoint, err)
+	}
+	client = NewSocket(t, ctx, clientType)
+	err = client.Connect(endpoint)
+	if err != nil {
+		t.Fatalf("zmqtest: connect %s: %v", endpoint, err)
+	}
+	return server, client
+}
+
+// Send sends a message with the given frames and fails the test on error
+func Send(t testing.TB, soc *zmq.Socket, parts ...[]byte) {
+	t.Helper()
+	err := soc.SendMultipart(parts, 0)
+	if err != nil {
+		t.Fatal("zmqtest: send:", err)
+	}
+}
+
+// Recv receives a message within the timeout and returns a copy of its
+// frames. The test fails when no message is received.
+func Recv(t testing.TB, soc *zmq.Socket, timeout time.Duration) [][]byte {
+	t.Helper()
+	items := zmq.PollItems{&zmq.PollItem{Socket: soc, Events: zmq.Pollin}}
+	n, err := items.Poll(timeout)
+	if err != nil {
+		t.Fatal("zmqtest: poll:", err)
+	}
+	if n == 0 {
+		t.Fatalf("zmqtest: no message received in %s", timeout)
+	}
+	msg, err := soc.RecvMultipart(0)
+	if err != nil {
+		t.Fatal("zmqtest: receive:", err)
+	}
+	defer msg.Close()
+	parts := make([][]byte, len(msg.Data))
+	for i, part := range msg.Data {
+		parts[i] = append([]byte{}, part...)
+	}
+	return parts
+}
+
+// ExpectMultipart fails the test unless a message with the given frames
+// is received within the timeout
+func ExpectMultipart(t testing.TB, soc *zmq.Socket, timeout time.Duration, parts ...[]byte) {
+	t.Helper()
+	received := Recv(t, soc, timeout)
+	if !equalParts(received, parts) {
+		t.Fatalf("zmqtest: expected message %q, got %q", parts, received)
+	}
+}
+
+// ExpectNoMessage fails the test when a message is received within the
+// timeout
+func ExpectNoMessage(t testing.TB, soc *zmq.Socket, timeout time.Duration) {
+	t.Helper()
+	items := zmq.PollItems{&zmq.PollItem{Socket: soc, Events: zmq.Pollin}}
+	n, err := items.Poll(timeout)
+	if err != nil {
+		t.Fatal("zmqtest: poll:", err)
+	}
+	if n > 0 {
+		t.Fatalf("zmqtest: unexpected message %q", Recv(t, soc, 0))
+	}
+}
+
+func equalParts(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
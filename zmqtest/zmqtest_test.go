@@ -0,0 +1,85 @@
+package zmqtest
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	zmq "github.com/bonnefoa/go-zeromq"
+)
+
+// failT records the failure of a helper instead of failing the test
+type failT struct {
+	*testing.T
+	failure string
+}
+
+func (f *failT) Fatal(args ...interface{}) {
+	f.failure = fmt.Sprint(args...)
+	runtime.Goexit()
+}
+
+func (f *failT) Fatalf(format string, args ...interface{}) {
+	f.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// failure runs the helper and returns its failure message
+func failure(t *testing.T, helper func(testing.TB)) string {
+	f := &failT{T: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		helper(f)
+	}()
+	<-done
+	return f.failure
+}
+
+func TestPair(t *testing.T) {
+	ctx := NewContext(t)
+	for _, endpoint := range []string{TCPEndpoint(t), InprocEndpoint(t)} {
+		server, client := Pair(t, ctx, zmq.Rep, zmq.Req, endpoint)
+		Send(t, client, []byte("ping"))
+		ExpectMultipart(t, server, time.Second, []byte("ping"))
+		Send(t, server, []byte("pong"), []byte("2"))
+		ExpectMultipart(t, client, time.Second, []byte("pong"), []byte("2"))
+	}
+}
+
+func TestExpectMultipartFailure(t *testing.T) {
+	ctx := NewContext(t)
+	server, client := Pair(t, ctx, zmq.Pull, zmq.Push, InprocEndpoint(t))
+	Send(t, client, []byte("a"), []byte("b"))
+	msg := failure(t, func(tb testing.TB) {
+		ExpectMultipart(tb, server, time.Second, []byte("a"))
+	})
+	if !strings.Contains(msg, `expected message ["a"], got ["a" "b"]`) {
+		t.Fatalf("Unexpected failure %q", msg)
+	}
+	msg = failure(t, func(tb testing.TB) {
+		ExpectMultipart(tb, server, 10*time.Millisecond, []byte("a"))
+	})
+	if !strings.Contains(msg, "no message received") {
+		t.Fatalf("Unexpected failure %q", msg)
+	}
+	ExpectNoMessage(t, server, 10*time.Millisecond)
+}
+
+func TestEndpoints(t *testing.T) {
+	if InprocEndpoint(t) == InprocEndpoint(t) {
+		t.Fatal("Expected unique inproc endpoints")
+	}
+	if !strings.HasPrefix(InprocEndpoint(t), "inproc://TestEndpoints-") {
+		t.Fatalf("Unexpected endpoint %s", InprocEndpoint(t))
+	}
+	ctx := NewContext(t)
+	soc := NewSocket(t, ctx, zmq.Pull)
+	endpoint := TCPEndpoint(t)
+	err := soc.Bind(endpoint)
+	if err != nil {
+		t.Fatal("Error on bind of free port", err)
+	}
+}
@@ -0,0 +1,250 @@
+package zmtp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMechanism is returned when peers use different security mechanisms
+var ErrMechanism = errors.New("zmtp: security mechanism mismatch")
+
+// ErrAuthentication is returned when PLAIN credentials are rejected
+var ErrAuthentication = errors.New("zmtp: authentication failed")
+
+// PeerError is an ERROR command received from the peer
+type PeerError struct {
+	Reason string
+}
+
+func (e *PeerError) Error() string {
+	return "zmtp: peer error: " + e.Reason
+}
+
+// Config holds the local settings of a connection handshake
+type Config struct {
+	// SocketType is the local socket type name, like "REQ"
+	SocketType string
+	// Identity is sent to the peer, routers use it to address this peer
+	Identity []byte
+	// Mechanism is MechanismNull when empty
+	Mechanism string
+	// AsServer is true for the PLAIN server side
+	AsServer bool
+	// Username and Password are the PLAIN client credentials
+	Username string
+	Password string
+	// Authenticate validates PLAIN credentials on the server side.
+	// All clients are accepted when nil.
+	Authenticate func(username, password string) bool
+	// MaxMessageSize limits the size of received frames when positive,
+	// DefaultMaxFrameSize is used otherwise
+	MaxMessageSize int64
+}
+
+// Conn is a ZMTP connection after a successful handshake
+type Conn struct {
+	// Peer is the greeting received from the peer
+	Peer Greeting
+	// PeerMetadata holds the properties sent by the peer
+	PeerMetadata Metadata
+
+	r       *bufio.Reader
+	w       *bufio.Writer
+	maxSize int64
+}
+
+// Handshake exchanges greetings and performs the security handshake
+// over the stream
+func Handshake(rw io.ReadWriter, cfg Config) (*Conn, error) {
+	if cfg.Mechanism == "" {
+		cfg.Mechanism = MechanismNull
+	}
+	c := &Conn{
+		r:       bufio.NewReader(rw),
+		w:       bufio.NewWriter(rw),
+		maxSize: cfg.MaxMessageSize,
+	}
+	greeting := Greeting{
+		Major:     VersionMajor,
+		Minor:     VersionMinor,
+		Mechanism: cfg.Mechanism,
+		AsServer:  cfg.AsServer,
+	}
+	c.w.Write(greeting.Marshal())
+	err := c.w.Flush()
+	if err != nil {
+		return nil, err
+	}
+	c.Peer, err = ReadGreeting(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if c.Peer.Mechanism != cfg.Mechanism {
+		return nil, ErrMechanism
+	}
+
+	metadata := Metadata{PropertySocketType: []byte(cfg.SocketType)}
+	if len(cfg.Identity) > 0 {
+		metadata[PropertyIdentity] = cfg.Identity
+	}
+	switch {
+	case cfg.Mechanism == MechanismNull:
+		err = c.WriteCommand(Command{Name: CommandReady, Data: metadata.Marshal()})
+		if err == nil {
+			c.PeerMetadata, err = c.expectMetadata(CommandReady)
+		}
+	case cfg.Mechanism == MechanismPlain && cfg.AsServer:
+		err = c.plainServer(cfg, metadata)
+	case cfg.Mechanism == MechanismPlain:
+		err = c.plainClient(cfg, metadata)
+	default:
+		err = ErrMechanism
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	peerType, _ := c.PeerMetadata.Get(PropertySocketType)
+	if !Compatible(cfg.SocketType, string(peerType)) {
+		reason := fmt.Sprintf("invalid socket type %s for %s", peerType, cfg.SocketType)
+		c.WriteCommand(Command{Name: CommandError, Data: errorReason(reason)})
+		return nil, errors.New("zmtp: " + reason)
+	}
+	return c, nil
+}
+
+func errorReason(reason string) []byte {
+	return append([]byte{byte(len(reason))}, reason...)
+}
+
+// Handshake commands are read from peers not authenticated yet, they
+// are limited regardless of MaxMessageSize
+const maxHandshakeCommandSize = 64 << 10
+
+// Read the next command, which must have the given name
+func (c *Conn) expectCommand(name string) (Command, error) {
+	f, err := ReadFrame(c.r, maxHandshakeCommandSize)
+	if err != nil {
+		return Command{}, err
+	}
+	if !f.Command {
+		return Command{}, ErrCommand
+	}
+	cmd, err := ParseCommand(f.Body)
+	if err != nil {
+		return Command{}, err
+	}
+	if cmd.Name == CommandError {
+		reason := cmd.Data
+		if len(reason) > 0 && int(reason[0]) <= len(reason)-1 {
+			reason = reason[1 : 1+int(reason[0])]
+		}
+		return Command{}, &PeerError{Reason: string(reason)}
+	}
+	if cmd.Name != name {
+		return Command{}, fmt.Errorf("zmtp: expected %s command, got %s", name, cmd.Name)
+	}
+	return cmd, nil
+}
+
+func (c *Conn) expectMetadata(name string) (Metadata, error) {
+	cmd, err := c.expectCommand(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMetadata(cmd.Data)
+}
+
+func (c *Conn) plainClient(cfg Config, metadata Metadata) error {
+	hello := []byte{byte(len(cfg.Username))}
+	hello = append(hello, cfg.Username...)
+	hello = append(hello, byte(len(cfg.Password)))
+	hello = append(hello, cfg.Password...)
+	err := c.WriteCommand(Command{Name: CommandHello, Data: hello})
+	if err != nil {
+		return err
+	}
+	_, err = c.expectCommand(CommandWelcome)
+	if err != nil {
+		return err
+	}
+	err = c.WriteCommand(Command{Name: CommandInitiate, Data: metadata.Marshal()})
+	if err != nil {
+		return err
+	}
+	c.PeerMetadata, err = c.expectMetadata(CommandReady)
+	return err
+}
+
+func (c *Conn) plainServer(cfg Config, metadata Metadata) error {
+	hello, err := c.expectCommand(CommandHello)
+	if err != nil {
+		return err
+	}
+	data := hello.Data
+	if len(data) < 1 || len(data) < 2+int(data[0]) {
+		return ErrCommand
+	}
+	username := string(data[1 : 1+int(data[0])])
+	data = data[1+int(data[0]):]
+	if len(data) < 1+int(data[0]) {
+		return ErrCommand
+	}
+	password := string(data[1 : 1+int(data[0])])
+	if cfg.Authenticate != nil && !cfg.Authenticate(username, password) {
+		c.WriteCommand(Command{Name: CommandError, Data: errorReason("invalid credentials")})
+		return ErrAuthentication
+	}
+	err = c.WriteCommand(Command{Name: CommandWelcome})
+	if err != nil {
+		return err
+	}
+	c.PeerMetadata, err = c.expectMetadata(CommandInitiate)
+	if err != nil {
+		return err
+	}
+	return c.WriteCommand(Command{Name: CommandReady, Data: metadata.Marshal()})
+}
+
+// WriteCommand sends a command
+func (c *Conn) WriteCommand(cmd Command) error {
+	c.w.Write(cmd.Frame().Marshal())
+	return c.w.Flush()
+}
+
+// WriteMessage sends a multipart message
+func (c *Conn) WriteMessage(frames [][]byte) error {
+	for i, frame := range frames {
+		f := Frame{More: i < len(frames)-1, Body: frame}
+		c.w.Write(f.Marshal())
+	}
+	return c.w.Flush()
+}
+
+// ReadMessage reads the next multipart message.
+// A command received between messages is returned alone with nil frames.
+func (c *Conn) ReadMessage() ([][]byte, *Command, error) {
+	var frames [][]byte
+	for {
+		f, err := ReadFrame(c.r, c.maxSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		if f.Command {
+			if len(frames) > 0 {
+				return nil, nil, ErrCommand
+			}
+			cmd, err := ParseCommand(f.Body)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, &cmd, nil
+		}
+		frames = append(frames, f.Body)
+		if !f.More {
+			return frames, nil, nil
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package zmtp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Socket type names of the ZMTP 2.0 greeting, indexed by their number
+var legacySocketTypes = []string{
+	"PAIR", "PUB", "SUB", "REQ", "REP", "DEALER", "ROUTER", "PULL", "PUSH", "XPUB", "XSUB",
+}
+
+// Revision of the ZMTP 2.0 greeting
+const revision2 = 1
+
+// Decoder decodes one direction of a ZMTP 1.0, 2.0 or 3.x connection,
+// for debugging tools
+type Decoder struct {
+	// Greeting is known once the greeting is read. Major is 1 for ZMTP 1.0
+	// and 2 for ZMTP 2.0, which have no security mechanism.
+	Greeting Greeting
+	// SocketType and Identity are known once the greeting
+	// or the metadata commands are read
+	SocketType string
+	Identity   []byte
+	// MaxFrameSize limits the size of decoded frames when positive,
+	// DefaultMaxFrameSize is used otherwise
+	MaxFrameSize int64
+
+	r       *rawReader
+	greeted bool
+}
+
+// rawReader records the bytes consumed by the decoder
+type rawReader struct {
+	*bufio.Reader
+	raw []byte
+}
+
+func (r *rawReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.raw = append(r.raw, p[:n]...)
+	return n, err
+}
+
+func (r *rawReader) ReadByte() (byte, error) {
+	b, err := r.Reader.ReadByte()
+	if err == nil {
+		r.raw = append(r.raw, b)
+	}
+	return b, err
+}
+
+// NewDecoder creates a decoder reading the stream sent by a peer
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: &rawReader{Reader: bufio.NewReader(r)}}
+}
+
+// Raw returns the bytes of the greeting, message or command last decoded.
+// They are valid until the next call to the decoder.
+func (d *Decoder) Raw() []byte {
+	return d.r.raw
+}
+
+// ReadGreeting detects the protocol version of the stream and decodes
+// its greeting. The identity sent by ZMTP 1.0 and 2.0 peers is read too.
+func (d *Decoder) ReadGreeting() (Greeting, error) {
+	if d.greeted {
+		return d.Greeting, nil
+	}
+	d.r.raw = d.r.raw[:0]
+	signature, err := d.r.Peek(signatureSize)
+	if err != nil && len(signature) == 0 {
+		return Greeting{}, err
+	}
+	// A ZMTP 1.0 peer starts with its identity frame, whose flags can not
+	// have the bit of the last signature byte set
+	if signature[0] != 0xff || len(signature) == signatureSize && signature[9]&0x01 == 0 {
+		d.Greeting = Greeting{Major: 1}
+		frame, err := d.readFrame()
+		if err != nil {
+			return Greeting{}, err
+		}
+		d.Identity = frame.Body
+		d.greeted = true
+		return d.Greeting, nil
+	}
+
+	header := make([]byte, signatureSize+1)
+	_, err = io.ReadFull(d.r, header)
+	if err != nil {
+		return Greeting{}, err
+	}
+	switch major := header[signatureSize]; {
+	case major == revision2:
+		socketType, err := d.r.ReadByte()
+		if err != nil {
+			return Greeting{}, err
+		}
+		if int(socketType) < len(legacySocketTypes) {
+			d.SocketType = legacySocketTypes[socketType]
+		}
+		d.Greeting = Greeting{Major: 2}
+		frame, err := ReadFrame(d.r, d.MaxFrameSize)
+		if err != nil {
+			return Greeting{}, err
+		}
+		d.Identity = frame.Body
+	case major >= VersionMajor:
+		data := make([]byte, GreetingSize)
+		copy(data, header)
+		_, err = io.ReadFull(d.r, data[len(header):])
+		if err != nil {
+			return Greeting{}, err
+		}
+		d.Greeting, err = ParseGreeting(data)
+		if err != nil {
+			return Greeting{}, err
+		}
+	default:
+		return Greeting{Major: major}, ErrVersion
+	}
+	d.greeted = true
+	return d.Greeting, nil
+}
+
+// readFrame reads a frame of the detected protocol version
+func (d *Decoder) readFrame() (Frame, error) {
+	if d.Greeting.Major != 1 {
+		return ReadFrame(d.r, d.MaxFrameSize)
+	}
+	// ZMTP 1.0 frames start with their size, including the flags byte
+	first, err := d.r.ReadByte()
+	if err != nil {
+		return Frame{}, err
+	}
+	size := uint64(first)
+	if first == 0xff {
+		var long [8]byte
+		_, err = io.ReadFull(d.r, long[:])
+		if err != nil {
+			return Frame{}, err
+		}
+		size = binary.BigEndian.Uint64(long[:])
+	}
+	if size == 0 {
+		return Frame{}, ErrFrameFlags
+	}
+	maxSize := d.MaxFrameSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+	if size-1 > uint64(maxSize) {
+		return Frame{}, ErrFrameTooBig
+	}
+	flags, err := d.r.ReadByte()
+	if err != nil {
+		return Frame{}, err
+	}
+	f := Frame{More: flags&flagMore != 0}
+	f.Body, err = readBody(d.r, int(size-1))
+	if err != nil {
+		return Frame{}, err
+	}
+	return f, nil
+}
+
+// ReadMessage reads the next multipart message, or the next command which is
+// returned alone with nil frames. The greeting is read first if needed.
+func (d *Decoder) ReadMessage() ([][]byte, *Command, error) {
+	if !d.greeted {
+		_, err := d.ReadGreeting()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	d.r.raw = d.r.raw[:0]
+	var frames [][]byte
+	for {
+		f, err := d.readFrame()
+		if err != nil {
+			if err == io.EOF && len(frames) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, nil, err
+		}
+		if f.Command && d.Greeting.Major >= VersionMajor {
+			if len(frames) > 0 {
+				return nil, nil, ErrCommand
+			}
+			cmd, err := ParseCommand(f.Body)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.learn(cmd)
+			return nil, &cmd, nil
+		}
+		frames = append(frames, f.Body)
+		if !f.More {
+			return frames, nil, nil
+		}
+	}
+}
+
+// learn records the properties sent in metadata commands
+func (d *Decoder) learn(cmd Command) {
+	if cmd.Name != CommandReady && cmd.Name != CommandInitiate {
+		return
+	}
+	metadata, err := ParseMetadata(cmd.Data)
+	if err != nil {
+		return
+	}
+	if socketType, ok := metadata.Get(PropertySocketType); ok {
+		d.SocketType = string(socketType)
+	}
+	if identity, ok := metadata.Get(PropertyIdentity); ok {
+		d.Identity = identity
+	}
+}
+
+func (g Greeting) String() string {
+	if g.Major < VersionMajor {
+		return fmt.Sprintf("ZMTP %d.0", g.Major)
+	}
+	role := "client"
+	if g.AsServer {
+		role = "server"
+	}
+	return fmt.Sprintf("ZMTP %d.%d %s %s", g.Major, g.Minor, g.Mechanism, role)
+}
+
+// Maximum number of bytes of a frame printed by FormatFrame
+const formatLimit = 64
+
+// FormatFrame prints a frame as a quoted string when it is text
+// and in hexadecimal otherwise. Long frames are truncated.
+func FormatFrame(frame []byte) string {
+	data := frame
+	if len(data) > formatLimit {
+		data = data[:formatLimit]
+	}
+	var s string
+	if utf8.Valid(data) && !strings.ContainsAny(string(data), "\x00") {
+		s = fmt.Sprintf("%q", data)
+	} else {
+		s = fmt.Sprintf("0x%x", data)
+	}
+	if len(frame) > formatLimit {
+		s += fmt.Sprintf("... (%d bytes)", len(frame))
+	}
+	return s
+}
+
+// FormatMessage prints a multipart message sent by a socket of the given
+// type. Subscriptions sent as messages by subscribers are recognized.
+func FormatMessage(socketType string, frames [][]byte) string {
+	if (socketType == "SUB" || socketType == "XSUB") && len(frames) == 1 &&
+		len(frames[0]) > 0 && frames[0][0] <= 1 {
+		name := CommandCancel
+		if frames[0][0] == 1 {
+			name = CommandSubscribe
+		}
+		return fmt.Sprintf("%s %s", name, FormatFrame(frames[0][1:]))
+	}
+	parts := make([]string, len(frames))
+	for i, frame := range frames {
+		parts[i] = FormatFrame(frame)
+	}
+	return fmt.Sprintf("message [%d] %s", len(frames), strings.Join(parts, " | "))
+}
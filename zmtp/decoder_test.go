@@ -0,0 +1,120 @@
+package zmtp
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecoderZMTP3(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(Greeting{Major: 3, Minor: 1, Mechanism: MechanismNull}.Marshal())
+	metadata := Metadata{PropertySocketType: []byte("DEALER"), PropertyIdentity: []byte("client")}
+	WriteFrame(&stream, Command{Name: CommandReady, Data: metadata.Marshal()}.Frame())
+	WriteFrame(&stream, Frame{More: true, Body: []byte{}})
+	WriteFrame(&stream, Frame{Body: []byte("hello")})
+	WriteFrame(&stream, Command{Name: CommandSubscribe, Data: []byte("topic")}.Frame())
+
+	d := NewDecoder(&stream)
+	g, err := d.ReadGreeting()
+	if err != nil {
+		t.Fatal("Error on greeting decoding", err)
+	}
+	if g.String() != "ZMTP 3.1 NULL client" {
+		t.Fatalf("Unexpected greeting %s", g)
+	}
+	_, cmd, err := d.ReadMessage()
+	if err != nil || cmd == nil {
+		t.Fatal("Error on ready decoding", err)
+	}
+	if cmd.String() != `READY Identity="client" Socket-Type="DEALER"` {
+		t.Fatalf("Unexpected command %s", cmd)
+	}
+	if d.SocketType != "DEALER" || string(d.Identity) != "client" {
+		t.Fatalf("Expected dealer client, got %s %q", d.SocketType, d.Identity)
+	}
+	frames, _, err := d.ReadMessage()
+	if err != nil {
+		t.Fatal("Error on message decoding", err)
+	}
+	if FormatMessage(d.SocketType, frames) != `message [2] "" | "hello"` {
+		t.Fatalf("Unexpected message %s", FormatMessage(d.SocketType, frames))
+	}
+	raw := append(Frame{More: true, Body: []byte{}}.Marshal(), Frame{Body: []byte("hello")}.Marshal()...)
+	if !bytes.Equal(d.Raw(), raw) {
+		t.Fatalf("Expected raw message %q, got %q", raw, d.Raw())
+	}
+	_, cmd, err = d.ReadMessage()
+	if err != nil || cmd.String() != `SUBSCRIBE "topic"` {
+		t.Fatalf("Unexpected command %v, err %v", cmd, err)
+	}
+	_, _, err = d.ReadMessage()
+	if err != io.EOF {
+		t.Fatalf("Expected end of stream, got %v", err)
+	}
+}
+
+func TestDecoderZMTP2(t *testing.T) {
+	stream := []byte{0xff, 0, 0, 0, 0, 0, 0, 0, 1, 0x7f, revision2, 2}
+	stream = append(stream, Frame{Body: []byte("id")}.Marshal()...)
+	stream = append(stream, Frame{Body: []byte{1, 'a'}}.Marshal()...)
+
+	d := NewDecoder(bytes.NewReader(stream))
+	frames, _, err := d.ReadMessage()
+	if err != nil {
+		t.Fatal("Error on message decoding", err)
+	}
+	if d.Greeting.Major != 2 || d.SocketType != "SUB" || string(d.Identity) != "id" {
+		t.Fatalf("Unexpected greeting %s %s %q", d.Greeting, d.SocketType, d.Identity)
+	}
+	if FormatMessage(d.SocketType, frames) != `SUBSCRIBE "a"` {
+		t.Fatalf("Unexpected subscription %s", FormatMessage(d.SocketType, frames))
+	}
+}
+
+func TestDecoderZMTP1(t *testing.T) {
+	// Identity frame, then a two frames message
+	stream := []byte{3, 0, 'i', 'd', 2, 1, 'a', 1, 0}
+	d := NewDecoder(bytes.NewReader(stream))
+	frames, _, err := d.ReadMessage()
+	if err != nil {
+		t.Fatal("Error on message decoding", err)
+	}
+	if d.Greeting.Major != 1 || string(d.Identity) != "id" {
+		t.Fatalf("Unexpected greeting %s %q", d.Greeting, d.Identity)
+	}
+	expected := [][]byte{[]byte("a"), {}}
+	if !reflect.DeepEqual(frames, expected) {
+		t.Fatalf("Expected frames %q, got %q", expected, frames)
+	}
+}
+
+func TestDecoderFrameTooBig(t *testing.T) {
+	hostile := []byte{0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0}
+	zmtp3 := append(Greeting{Major: 3, Minor: 1, Mechanism: MechanismNull}.Marshal(), flagLong)
+	zmtp3 = append(zmtp3, hostile[1:9]...)
+	for _, stream := range [][]byte{hostile, zmtp3} {
+		d := NewDecoder(bytes.NewReader(stream))
+		_, _, err := d.ReadMessage()
+		if err != ErrFrameTooBig {
+			t.Fatalf("Expected error %q, got %q", ErrFrameTooBig, err)
+		}
+	}
+	d := NewDecoder(bytes.NewReader([]byte{3, 0, 'i', 'd', 200, 0}))
+	d.MaxFrameSize = 100
+	_, _, err := d.ReadMessage()
+	if err != ErrFrameTooBig {
+		t.Fatalf("Expected error %q, got %q", ErrFrameTooBig, err)
+	}
+}
+
+func TestFormatFrame(t *testing.T) {
+	if FormatFrame([]byte{0, 0xff}) != "0x00ff" {
+		t.Fatalf("Expected binary frame in hexadecimal, got %s", FormatFrame([]byte{0, 0xff}))
+	}
+	long := FormatFrame(bytes.Repeat([]byte("x"), 100))
+	if long != `"`+string(bytes.Repeat([]byte("x"), formatLimit))+`"... (100 bytes)` {
+		t.Fatalf("Expected truncated frame, got %s", long)
+	}
+}
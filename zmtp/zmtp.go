@@ -0,0 +1,352 @@
+// Package zmtp implements the ZeroMQ Message Transport Protocol:
+// greetings, frames, commands and the security handshake of ZMTP 3.x
+// as specified by https://rfc.zeromq.org/spec/23 and 37.
+// Its Decoder also reads the older ZMTP 1.0 and 2.0 streams.
+package zmtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Protocol version implemented by this package
+const (
+	VersionMajor = 3
+	VersionMinor = 1
+)
+
+// Security mechanisms
+const (
+	MechanismNull  = "NULL"
+	MechanismPlain = "PLAIN"
+)
+
+// Command names
+const (
+	CommandReady     = "READY"
+	CommandError     = "ERROR"
+	CommandHello     = "HELLO"
+	CommandWelcome   = "WELCOME"
+	CommandInitiate  = "INITIATE"
+	CommandSubscribe = "SUBSCRIBE"
+	CommandCancel    = "CANCEL"
+	CommandPing      = "PING"
+	CommandPong      = "PONG"
+)
+
+// Metadata property names
+const (
+	PropertySocketType = "Socket-Type"
+	PropertyIdentity   = "Identity"
+)
+
+// Frame flags
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// Size of the greeting and its parts
+const (
+	GreetingSize   = 64
+	signatureSize  = 10
+	mechanismSize  = 20
+	greetingFiller = 31
+)
+
+// Protocol errors
+var (
+	ErrSignature   = errors.New("zmtp: invalid greeting signature")
+	ErrVersion     = errors.New("zmtp: unsupported protocol version")
+	ErrFrameFlags  = errors.New("zmtp: invalid frame flags")
+	ErrCommand     = errors.New("zmtp: malformed command")
+	ErrMetadata    = errors.New("zmtp: malformed metadata")
+	ErrFrameTooBig = errors.New("zmtp: frame exceeds maximum size")
+)
+
+// Greeting is the first part of the exchange between two peers
+type Greeting struct {
+	Major     uint8
+	Minor     uint8
+	Mechanism string
+	AsServer  bool
+}
+
+// Marshal encodes the greeting on 64 bytes
+func (g Greeting) Marshal() []byte {
+	data := make([]byte, GreetingSize)
+	data[0] = 0xff
+	data[9] = 0x7f
+	data[10] = g.Major
+	data[11] = g.Minor
+	copy(data[12:12+mechanismSize], g.Mechanism)
+	if g.AsServer {
+		data[32] = 1
+	}
+	return data
+}
+
+// ReadGreeting reads and decodes a ZMTP 3.x greeting
+func ReadGreeting(r io.Reader) (Greeting, error) {
+	data := make([]byte, GreetingSize)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return Greeting{}, err
+	}
+	return ParseGreeting(data)
+}
+
+// ParseGreeting decodes a 64 bytes ZMTP 3.x greeting
+func ParseGreeting(data []byte) (Greeting, error) {
+	if len(data) < GreetingSize || data[0] != 0xff || data[9]&0x01 == 0 {
+		return Greeting{}, ErrSignature
+	}
+	g := Greeting{
+		Major:     data[10],
+		Minor:     data[11],
+		Mechanism: string(bytes.TrimRight(data[12:12+mechanismSize], "\x00")),
+		AsServer:  data[32] == 1,
+	}
+	if g.Major < 3 {
+		return g, ErrVersion
+	}
+	return g, nil
+}
+
+// Frame is a message part or a command
+type Frame struct {
+	More    bool
+	Command bool
+	Body    []byte
+}
+
+// DefaultMaxFrameSize limits the size of frames read without a maximum
+const DefaultMaxFrameSize = 256 << 20
+
+// Frame bodies are read by chunks so that the size announced by a peer
+// is only allocated as the data is received
+const readChunkSize = 64 << 10
+
+// ReadFrame reads a frame whose size does not exceed maxSize, if positive,
+// or DefaultMaxFrameSize
+func ReadFrame(r io.Reader, maxSize int64) (Frame, error) {
+	var header [9]byte
+	_, err := io.ReadFull(r, header[:1])
+	if err != nil {
+		return Frame{}, err
+	}
+	flags := header[0]
+	if flags&^(flagMore|flagLong|flagCommand) != 0 {
+		return Frame{}, ErrFrameFlags
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		_, err = io.ReadFull(r, header[1:9])
+		size = binary.BigEndian.Uint64(header[1:9])
+	} else {
+		_, err = io.ReadFull(r, header[1:2])
+		size = uint64(header[1])
+	}
+	if err != nil {
+		return Frame{}, err
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+	if size > uint64(maxSize) {
+		return Frame{}, ErrFrameTooBig
+	}
+	f := Frame{More: flags&flagMore != 0, Command: flags&flagCommand != 0}
+	f.Body, err = readBody(r, int(size))
+	if err != nil {
+		return Frame{}, err
+	}
+	return f, nil
+}
+
+// readBody reads size bytes by chunks of readChunkSize
+func readBody(r io.Reader, size int) ([]byte, error) {
+	body := make([]byte, 0, min(size, readChunkSize))
+	for len(body) < size {
+		start := len(body)
+		n := min(size-start, readChunkSize)
+		body = slices.Grow(body, n)[:start+n]
+		_, err := io.ReadFull(r, body[start:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// Marshal encodes the frame with its header
+func (f Frame) Marshal() []byte {
+	var flags byte
+	if f.More {
+		flags |= flagMore
+	}
+	if f.Command {
+		flags |= flagCommand
+	}
+	size := len(f.Body)
+	var data []byte
+	if size > 255 {
+		data = make([]byte, 9, 9+size)
+		data[0] = flags | flagLong
+		binary.BigEndian.PutUint64(data[1:9], uint64(size))
+	} else {
+		data = make([]byte, 2, 2+size)
+		data[0] = flags
+		data[1] = byte(size)
+	}
+	return append(data, f.Body...)
+}
+
+// WriteFrame writes the frame
+func WriteFrame(w io.Writer, f Frame) error {
+	_, err := w.Write(f.Marshal())
+	return err
+}
+
+// Command is a control frame exchanged between peers
+type Command struct {
+	Name string
+	Data []byte
+}
+
+// ParseCommand decodes the body of a command frame
+func ParseCommand(body []byte) (Command, error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) {
+		return Command{}, ErrCommand
+	}
+	size := int(body[0])
+	return Command{Name: string(body[1 : 1+size]), Data: body[1+size:]}, nil
+}
+
+// Frame encodes the command as a command frame
+func (c Command) Frame() Frame {
+	body := make([]byte, 0, 1+len(c.Name)+len(c.Data))
+	body = append(body, byte(len(c.Name)))
+	body = append(body, c.Name...)
+	body = append(body, c.Data...)
+	return Frame{Command: true, Body: body}
+}
+
+func (c Command) String() string {
+	switch c.Name {
+	case CommandReady, CommandInitiate:
+		metadata, err := ParseMetadata(c.Data)
+		if err != nil {
+			break
+		}
+		names := make([]string, 0, len(metadata))
+		for name := range metadata {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		s := c.Name
+		for _, name := range names {
+			s += fmt.Sprintf(" %s=%s", name, FormatFrame(metadata[name]))
+		}
+		return s
+	case CommandError:
+		if len(c.Data) > 0 && len(c.Data) > int(c.Data[0]) {
+			return fmt.Sprintf("%s %q", c.Name, c.Data[1:1+c.Data[0]])
+		}
+	case CommandSubscribe, CommandCancel:
+		return fmt.Sprintf("%s %s", c.Name, FormatFrame(c.Data))
+	case CommandPing:
+		if len(c.Data) >= 2 {
+			ttl := binary.BigEndian.Uint16(c.Data)
+			return fmt.Sprintf("%s ttl=%d context=%s", c.Name, ttl, FormatFrame(c.Data[2:]))
+		}
+	case CommandPong:
+		return fmt.Sprintf("%s context=%s", c.Name, FormatFrame(c.Data))
+	}
+	return fmt.Sprintf("%s %q", c.Name, c.Data)
+}
+
+// Metadata holds the properties sent in READY and INITIATE commands
+type Metadata map[string][]byte
+
+// ParseMetadata decodes a list of properties
+func ParseMetadata(data []byte) (Metadata, error) {
+	m := Metadata{}
+	for len(data) > 0 {
+		nameSize := int(data[0])
+		if len(data) < 1+nameSize+4 {
+			return nil, ErrMetadata
+		}
+		name := string(data[1 : 1+nameSize])
+		data = data[1+nameSize:]
+		valueSize := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		if uint64(len(data)) < uint64(valueSize) {
+			return nil, ErrMetadata
+		}
+		m[name] = data[:valueSize]
+		data = data[valueSize:]
+	}
+	return m, nil
+}
+
+// Marshal encodes the properties sorted by name
+func (m Metadata) Marshal() []byte {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteByte(byte(len(name)))
+		buf.WriteString(name)
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(m[name])))
+		buf.Write(size[:])
+		buf.Write(m[name])
+	}
+	return buf.Bytes()
+}
+
+// Get returns a property, names are case insensitive
+func (m Metadata) Get(name string) ([]byte, bool) {
+	for key, value := range m {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Compatible reports whether two socket types can be connected together
+func Compatible(local, remote string) bool {
+	switch local {
+	case "REQ":
+		return remote == "REP" || remote == "ROUTER"
+	case "REP":
+		return remote == "REQ" || remote == "DEALER"
+	case "DEALER":
+		return remote == "REP" || remote == "DEALER" || remote == "ROUTER"
+	case "ROUTER":
+		return remote == "REQ" || remote == "DEALER" || remote == "ROUTER"
+	case "PUB", "XPUB":
+		return remote == "SUB" || remote == "XSUB"
+	case "SUB", "XSUB":
+		return remote == "PUB" || remote == "XPUB"
+	case "PUSH":
+		return remote == "PULL"
+	case "PULL":
+		return remote == "PUSH"
+	case "PAIR":
+		return remote == "PAIR"
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package zmtp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestGreeting(t *testing.T) {
+	g := Greeting{Major: 3, Minor: 1, Mechanism: MechanismPlain, AsServer: true}
+	data := g.Marshal()
+	if len(data) != GreetingSize {
+		t.Fatalf("Expected greeting size %d, got %d", GreetingSize, len(data))
+	}
+	parsed, err := ParseGreeting(data)
+	if err != nil {
+		t.Fatal("Error on greeting parsing", err)
+	}
+	if parsed != g {
+		t.Fatalf("Expected greeting %+v, got %+v", g, parsed)
+	}
+	data[0] = 0
+	_, err = ParseGreeting(data)
+	if err != ErrSignature {
+		t.Fatalf("Expected error %q, got %q", ErrSignature, err)
+	}
+}
+
+func TestFrame(t *testing.T) {
+	for _, f := range []Frame{
+		{More: true, Body: []byte("short")},
+		{Body: bytes.Repeat([]byte("x"), 300)},
+		{Command: true, Body: []byte{}},
+	} {
+		parsed, err := ReadFrame(bytes.NewReader(f.Marshal()), 0)
+		if err != nil {
+			t.Fatal("Error on frame reading", err)
+		}
+		if !reflect.DeepEqual(parsed, f) {
+			t.Fatalf("Expected frame %+v, got %+v", f, parsed)
+		}
+	}
+	long := Frame{Body: make([]byte, 1000)}
+	_, err := ReadFrame(bytes.NewReader(long.Marshal()), 100)
+	if err != ErrFrameTooBig {
+		t.Fatalf("Expected error %q, got %q", ErrFrameTooBig, err)
+	}
+}
+
+func TestFrameHostileSize(t *testing.T) {
+	hostile := []byte{flagLong, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	for _, maxSize := range []int64{0, -1, 100} {
+		_, err := ReadFrame(bytes.NewReader(hostile), maxSize)
+		if err != ErrFrameTooBig {
+			t.Fatalf("Expected error %q with maximum %d, got %q", ErrFrameTooBig, maxSize, err)
+		}
+	}
+	// A truncated frame fails without allocating its announced size
+	truncated := []byte{flagLong, 0, 0, 0, 0, 0x0f, 0xff, 0xff, 0xff, 'x'}
+	_, err := ReadFrame(bytes.NewReader(truncated), 0)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected error %q, got %q", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	m := Metadata{PropertySocketType: []byte("REQ"), PropertyIdentity: []byte("id")}
+	parsed, err := ParseMetadata(m.Marshal())
+	if err != nil {
+		t.Fatal("Error on metadata parsing", err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Fatalf("Expected metadata %q, got %q", m, parsed)
+	}
+	value, ok := parsed.Get("socket-type")
+	if !ok || string(value) != "REQ" {
+		t.Fatalf("Expected case insensitive socket type, got %q", value)
+	}
+}
+
+// Run both sides of a handshake over a local tcp connection
+func handshakePair(t *testing.T, client, server Config) (*Conn, *Conn, error, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	accepted := make(chan result)
+	go func() {
+		nc, err := listener.Accept()
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		conn, err := Handshake(nc, server)
+		accepted <- result{conn, err}
+	}()
+	nc, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, cerr := Handshake(nc, client)
+	res := <-accepted
+	return conn, res.conn, cerr, res.err
+}
+
+func TestHandshakeNull(t *testing.T) {
+	client, server, cerr, serr := handshakePair(t,
+		Config{SocketType: "DEALER", Identity: []byte("me")},
+		Config{SocketType: "ROUTER"})
+	if cerr != nil || serr != nil {
+		t.Fatalf("Error on handshake: %v, %v", cerr, serr)
+	}
+	identity, _ := server.PeerMetadata.Get(PropertyIdentity)
+	if string(identity) != "me" {
+		t.Fatalf("Expected peer identity 'me', got %q", identity)
+	}
+	data := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("y"), 1000)}
+	go client.WriteMessage(data)
+	frames, cmd, err := server.ReadMessage()
+	if err != nil || cmd != nil {
+		t.Fatalf("Error on message read: %v, %v", cmd, err)
+	}
+	if !reflect.DeepEqual(frames, data) {
+		t.Fatalf("Expected message %q, got %q", data, frames)
+	}
+}
+
+func TestHandshakePlain(t *testing.T) {
+	auth := func(username, password string) bool {
+		return username == "admin" && password == "secret"
+	}
+	_, _, cerr, serr := handshakePair(t,
+		Config{SocketType: "REQ", Mechanism: MechanismPlain, Username: "admin", Password: "secret"},
+		Config{SocketType: "REP", Mechanism: MechanismPlain, AsServer: true, Authenticate: auth})
+	if cerr != nil || serr != nil {
+		t.Fatalf("Error on handshake: %v, %v", cerr, serr)
+	}
+	_, _, cerr, serr = handshakePair(t,
+		Config{SocketType: "REQ", Mechanism: MechanismPlain, Username: "admin", Password: "wrong"},
+		Config{SocketType: "REP", Mechanism: MechanismPlain, AsServer: true, Authenticate: auth})
+	if _, ok := cerr.(*PeerError); !ok || serr != ErrAuthentication {
+		t.Fatalf("Expected authentication failure, got %v, %v", cerr, serr)
+	}
+}
+
+func TestHandshakeIncompatible(t *testing.T) {
+	_, _, cerr, serr := handshakePair(t, Config{SocketType: "PUSH"}, Config{SocketType: "PUB"})
+	if cerr == nil || serr == nil {
+		t.Fatal("Expected incompatible socket types to fail")
+	}
+}
+
+func TestHandshakeHostileCommand(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		client.Write(Greeting{Major: VersionMajor, Minor: VersionMinor, Mechanism: MechanismNull}.Marshal())
+		client.Write([]byte{flagLong | flagCommand, 0, 0, 0, 0, 0, 0x10, 0, 0})
+	}()
+	go io.Copy(io.Discard, client)
+	_, err := Handshake(server, Config{SocketType: "ROUTER", MaxMessageSize: -1})
+	if err != ErrFrameTooBig {
+		t.Fatalf("Expected error %q, got %q", ErrFrameTooBig, err)
+	}
+}